@@ -0,0 +1,305 @@
+package bytecode
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	headerPattern      = regexp.MustCompile(`^== (.*) ==$`)
+	instructionPattern = regexp.MustCompile(`^(\d+) +(\d+|\|) (OP_\w+) *(.*)$`)
+	upvaluePattern     = regexp.MustCompile(`^(\d+) +\| +(local|upvalue) (\d+)$`)
+	constantOperand    = regexp.MustCompile(`^(\d+) (.+)$`)
+	byteOperand        = regexp.MustCompile(`^(\d+)$`)
+	jumpOperand        = regexp.MustCompile(`^(\d+) -> (\d+)$`)
+	invokeOperand      = regexp.MustCompile(`^\((\d+) args\) +(\d+) (.+)$`)
+	functionConstant   = regexp.MustCompile(`^<fn (.*)>$`)
+)
+
+type section struct {
+	name  string
+	lines []sourceLine
+}
+
+type sourceLine struct {
+	number int
+	text   string
+}
+
+type assembler struct {
+	sections []section
+	next     int
+}
+
+// Assemble parses a listing in the format produced by Disassemble back into
+// a chunk. Function constants are rebuilt from the sections that follow.
+func Assemble(listing string) (*Chunk, error) {
+	a := &assembler{}
+	if err := a.split(listing); err != nil {
+		return nil, err
+	}
+	if len(a.sections) == 0 {
+		return nil, fmt.Errorf("missing '== name ==' header")
+	}
+
+	function, err := a.assembleSection()
+	if err != nil {
+		return nil, err
+	}
+	if a.next != len(a.sections) {
+		return nil, fmt.Errorf("unreferenced function listing %q", a.sections[a.next].name)
+	}
+	return function.Chunk, nil
+}
+
+func (a *assembler) split(listing string) error {
+	for i, text := range strings.Split(listing, "\n") {
+		text = strings.TrimRight(text, " \t\r")
+		if text == "" {
+			continue
+		}
+
+		if match := headerPattern.FindStringSubmatch(text); match != nil {
+			a.sections = append(a.sections, section{name: match[1]})
+			continue
+		}
+
+		if len(a.sections) == 0 {
+			return fmt.Errorf("line %d: expected '== name ==' header", i+1)
+		}
+		current := &a.sections[len(a.sections)-1]
+		current.lines = append(current.lines, sourceLine{i + 1, text})
+	}
+	return nil
+}
+
+func (a *assembler) assembleSection() (*ObjFunction, error) {
+	sec := a.sections[a.next]
+	a.next++
+
+	function := NewFunction()
+	if a.next > 1 {
+		slash := strings.LastIndex(sec.name, "/")
+		if slash == -1 {
+			return nil, fmt.Errorf("function header %q has no arity", sec.name)
+		}
+		arity, err := strconv.Atoi(sec.name[slash+1:])
+		if err != nil {
+			return nil, fmt.Errorf("function header %q: bad arity", sec.name)
+		}
+		function.Name = sec.name[:slash]
+		function.Arity = arity
+	}
+
+	chunk := function.Chunk
+	constants := make(map[int]Value)
+	upvalueCounts := make(map[int]int)
+	closure := -1
+	line := 0
+
+	for _, src := range sec.lines {
+		if match := upvaluePattern.FindStringSubmatch(src.text); match != nil {
+			if closure == -1 {
+				return nil, fmt.Errorf("line %d: upvalue outside of OP_CLOSURE", src.number)
+			}
+			if err := checkOffset(chunk, match[1], src.number); err != nil {
+				return nil, err
+			}
+			index, err := parseByte(match[3], src.number)
+			if err != nil {
+				return nil, err
+			}
+			isLocal := byte(0)
+			if match[2] == "local" {
+				isLocal = 1
+			}
+			chunk.Write(isLocal, line)
+			chunk.Write(index, line)
+			upvalueCounts[closure]++
+			continue
+		}
+
+		match := instructionPattern.FindStringSubmatch(src.text)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: cannot parse %q", src.number, src.text)
+		}
+		if err := checkOffset(chunk, match[1], src.number); err != nil {
+			return nil, err
+		}
+		if match[2] != "|" {
+			line, _ = strconv.Atoi(match[2])
+		}
+		op, ok := LookupOpcode(match[3])
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown opcode %s", src.number, match[3])
+		}
+
+		offset := chunk.Count()
+		operands := match[4]
+		closure = -1
+		chunk.WriteOp(op, line)
+
+		switch op {
+		case OP_CONSTANT, OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL,
+			OP_GET_PROPERTY, OP_SET_PROPERTY, OP_CLASS, OP_METHOD, OP_CLOSURE:
+			m := constantOperand.FindStringSubmatch(operands)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: %s expects a constant", src.number, op)
+			}
+			index, err := addConstant(constants, m[1], m[2], src.number)
+			if err != nil {
+				return nil, err
+			}
+			chunk.Write(index, line)
+			if op == OP_CLOSURE {
+				if _, ok := constants[int(index)].(*ObjFunction); !ok {
+					return nil, fmt.Errorf("line %d: OP_CLOSURE expects a function", src.number)
+				}
+				closure = int(index)
+			}
+		case OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_CALL:
+			m := byteOperand.FindStringSubmatch(operands)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: %s expects a byte operand", src.number, op)
+			}
+			operand, err := parseByte(m[1], src.number)
+			if err != nil {
+				return nil, err
+			}
+			chunk.Write(operand, line)
+		case OP_JUMP, OP_JUMP_IF_FALSE, OP_LOOP:
+			m := jumpOperand.FindStringSubmatch(operands)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: %s expects 'offset -> target'", src.number, op)
+			}
+			target, _ := strconv.Atoi(m[2])
+			jump := target - (offset + 3)
+			if op == OP_LOOP {
+				jump = -jump
+			}
+			if jump < 0 || jump > 0xffff {
+				return nil, fmt.Errorf("line %d: jump target %d out of range", src.number, target)
+			}
+			chunk.Write(byte(jump>>8&0xff), line)
+			chunk.Write(byte(jump&0xff), line)
+		case OP_INVOKE:
+			m := invokeOperand.FindStringSubmatch(operands)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: OP_INVOKE expects '(n args) constant value'", src.number)
+			}
+			index, err := addConstant(constants, m[2], m[3], src.number)
+			if err != nil {
+				return nil, err
+			}
+			argCount, err := parseByte(m[1], src.number)
+			if err != nil {
+				return nil, err
+			}
+			chunk.Write(index, line)
+			chunk.Write(argCount, line)
+		default:
+			if operands != "" {
+				return nil, fmt.Errorf("line %d: %s takes no operands", src.number, op)
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(constants))
+	for index := range constants {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for i, index := range indexes {
+		if i != index {
+			return nil, fmt.Errorf("function %q: constant %d is never referenced", sec.name, i)
+		}
+
+		value := constants[index]
+		if placeholder, ok := value.(*ObjFunction); ok {
+			if a.next >= len(a.sections) {
+				return nil, fmt.Errorf("function %q: missing listing for <fn %s>", sec.name, placeholder.Name)
+			}
+			nested, err := a.assembleSection()
+			if err != nil {
+				return nil, err
+			}
+			if nested.Name != placeholder.Name {
+				return nil, fmt.Errorf("function %q: expected listing for <fn %s>, got %q", sec.name, placeholder.Name, nested.Name)
+			}
+			nested.UpvalueCount = upvalueCounts[index]
+			value = nested
+		}
+		chunk.AddConstant(value)
+	}
+
+	return function, nil
+}
+
+func checkOffset(chunk *Chunk, text string, number int) error {
+	offset, _ := strconv.Atoi(text)
+	if offset != chunk.Count() {
+		return fmt.Errorf("line %d: offset %04d does not match %04d", number, offset, chunk.Count())
+	}
+	return nil
+}
+
+func parseByte(text string, number int) (byte, error) {
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 0 || n > 0xff {
+		return 0, fmt.Errorf("line %d: operand %s out of range", number, text)
+	}
+	return byte(n), nil
+}
+
+func addConstant(constants map[int]Value, indexText, valueText string, number int) (byte, error) {
+	index, err := parseByte(indexText, number)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := parseConstant(valueText)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: %v", number, err)
+	}
+
+	if existing, ok := constants[int(index)]; ok && formatConstant(existing) != formatConstant(value) {
+		return 0, fmt.Errorf("line %d: constant %d redefined as %s", number, index, valueText)
+	}
+	constants[int(index)] = value
+	return index, nil
+}
+
+// parseConstant reads back a value written by formatConstant. Functions come
+// back as placeholders naming the listing that defines them.
+func parseConstant(text string) (Value, error) {
+	switch text {
+	case "nil":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	if strings.HasPrefix(text, `"`) {
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("bad string constant %s", text)
+		}
+		return s, nil
+	}
+
+	if match := functionConstant.FindStringSubmatch(text); match != nil {
+		return &ObjFunction{Name: match[1]}, nil
+	}
+
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad constant %s", text)
+	}
+	return n, nil
+}
@@ -0,0 +1,110 @@
+package bytecode
+
+import (
+	"strings"
+	"testing"
+)
+
+const roundTripSource = `
+var greeting = "hello" + " " + "world";
+fun makeCounter() {
+	var i = 0;
+	fun count() {
+		i = i + 1;
+		return i;
+	}
+	return count;
+}
+class Point {
+	init(x, y) {
+		this.x = x;
+		this.y = y;
+	}
+	sum() { return this.x + this.y; }
+}
+var counter = makeCounter();
+for (var i = 0; i < 3; i = i + 1) counter();
+var result = counter() * 30 + Point(1, 2).sum();
+while (result > 100 and !false) result = result - 1;
+var lambda = fun (a) { return a; };
+`
+
+func TestAssembleRoundTrip(t *testing.T) {
+	function := CompileSource(roundTripSource)
+	if function == nil {
+		t.Fatal("compile error")
+	}
+
+	listing := Disassemble(function.Chunk, "script")
+	chunk, err := Assemble(listing)
+	if err != nil {
+		t.Fatalf("Assemble: %v\n%s", err, listing)
+	}
+
+	if again := Disassemble(chunk, "script"); again != listing {
+		t.Errorf("listing changed after round trip:\n%s\nwant:\n%s", again, listing)
+	}
+
+	vm := New()
+	if result := vm.Run(&ObjFunction{Chunk: chunk}); result != INTERPRET_OK {
+		t.Fatalf("Run returned %d", result)
+	}
+	if got := vm.globals["result"]; got != 100.0 {
+		t.Errorf("result = %v, want 100", got)
+	}
+	if got := vm.globals["greeting"]; got != "hello world" {
+		t.Errorf("greeting = %v, want hello world", got)
+	}
+}
+
+func TestAssembleHandWritten(t *testing.T) {
+	listing := `
+== script ==
+0000    1 OP_CONSTANT         0 1.5
+0002    | OP_CONSTANT         1 2
+0004    | OP_MULTIPLY
+0005    2 OP_DEFINE_GLOBAL    2 "result"
+0007    | OP_NIL
+0008    | OP_RETURN
+`
+	chunk, err := Assemble(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := chunk.GetLine(5); line != 2 {
+		t.Errorf("GetLine(5) = %d, want 2", line)
+	}
+	if len(chunk.Lines) != 2 {
+		t.Errorf("got %d line runs, want 2", len(chunk.Lines))
+	}
+
+	vm := New()
+	if result := vm.Run(&ObjFunction{Chunk: chunk}); result != INTERPRET_OK {
+		t.Fatalf("Run returned %d", result)
+	}
+	if got := vm.globals["result"]; got != 3.0 {
+		t.Errorf("result = %v, want 3", got)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		listing string
+		want    string
+	}{
+		{"0000    1 OP_NIL", "header"},
+		{"== s ==\n0000    1 OP_BOGUS", "unknown opcode"},
+		{"== s ==\n0001    1 OP_NIL", "offset"},
+		{"== s ==\n0000    1 OP_CONSTANT         1 2", "never referenced"},
+		{"== s ==\n0000    1 OP_CLOSURE          0 <fn f>", "missing listing"},
+		{"== s ==\n0000    1 OP_JUMP             0 -> 1", "out of range"},
+		{"== s ==\n0000    1 OP_RETURN 3", "no operands"},
+	}
+
+	for _, tt := range tests {
+		_, err := Assemble(tt.listing)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got error %v, want %q", tt.listing, err, tt.want)
+		}
+	}
+}
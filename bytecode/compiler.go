@@ -0,0 +1,945 @@
+package bytecode
+
+import (
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/token"
+	"math"
+)
+
+const UINT8_COUNT = math.MaxUint8 + 1
+
+type Precedence int
+
+const (
+	PREC_NONE Precedence = iota
+	PREC_ASSIGNMENT
+	PREC_OR
+	PREC_AND
+	PREC_EQUALITY
+	PREC_COMPARISON
+	PREC_TERM
+	PREC_FACTOR
+	PREC_UNARY
+	PREC_CALL
+	PREC_PRIMARY
+)
+
+type ParseFn func(c *Compiler, canAssign bool)
+
+type ParseRule struct {
+	prefix     ParseFn
+	infix      ParseFn
+	precedence Precedence
+}
+
+var rules map[token.TokenType]ParseRule
+
+func init() {
+	rules = map[token.TokenType]ParseRule{
+		token.LEFT_PAREN:    {(*Compiler).grouping, (*Compiler).call, PREC_CALL},
+		token.DOT:           {nil, (*Compiler).dot, PREC_CALL},
+		token.MINUS:         {(*Compiler).unary, (*Compiler).binary, PREC_TERM},
+		token.PLUS:          {nil, (*Compiler).binary, PREC_TERM},
+		token.SLASH:         {nil, (*Compiler).binary, PREC_FACTOR},
+		token.STAR:          {nil, (*Compiler).binary, PREC_FACTOR},
+		token.BANG:          {(*Compiler).unary, nil, PREC_NONE},
+		token.BANG_EQUAL:    {nil, (*Compiler).binary, PREC_EQUALITY},
+		token.EQUAL_EQUAL:   {nil, (*Compiler).binary, PREC_EQUALITY},
+		token.GREATER:       {nil, (*Compiler).binary, PREC_COMPARISON},
+		token.GREATER_EQUAL: {nil, (*Compiler).binary, PREC_COMPARISON},
+		token.LESS:          {nil, (*Compiler).binary, PREC_COMPARISON},
+		token.LESS_EQUAL:    {nil, (*Compiler).binary, PREC_COMPARISON},
+		token.IDENTIFIER:    {(*Compiler).variable, nil, PREC_NONE},
+		token.STRING:        {(*Compiler).str, nil, PREC_NONE},
+		token.INTERPOLATION: {(*Compiler).interpolation, nil, PREC_NONE},
+		token.NUMBER:        {(*Compiler).number, nil, PREC_NONE},
+		token.AND:           {nil, (*Compiler).and, PREC_AND},
+		token.FALSE:         {(*Compiler).literal, nil, PREC_NONE},
+		token.FUN:           {(*Compiler).lambda, nil, PREC_NONE},
+		token.NIL:           {(*Compiler).literal, nil, PREC_NONE},
+		token.OR:            {nil, (*Compiler).or, PREC_OR},
+		token.THIS:          {(*Compiler).this, nil, PREC_NONE},
+		token.TRUE:          {(*Compiler).literal, nil, PREC_NONE},
+	}
+}
+
+func getRule(typ token.TokenType) ParseRule {
+	return rules[typ]
+}
+
+type FunctionType int
+
+const (
+	TYPE_FUNCTION FunctionType = iota
+	TYPE_INITIALIZER
+	TYPE_METHOD
+	TYPE_SCRIPT
+)
+
+type Local struct {
+	name       token.Token
+	depth      int
+	isCaptured bool
+}
+
+type Upvalue struct {
+	index   byte
+	isLocal bool
+}
+
+type ClassCompiler struct {
+	enclosing *ClassCompiler
+}
+
+// Loop is a loop being compiled, for break and continue.
+type Loop struct {
+	enclosing *Loop
+	// start is where continue jumps to: the increment of a for loop, or
+	// the condition.
+	start int
+	// scopeDepth is the depth of the scope around the loop body.
+	scopeDepth int
+	// breaks are the jumps to patch to the end of the loop.
+	breaks []int
+}
+
+type Parser struct {
+	tokens       []token.Token
+	next         int
+	current      token.Token
+	previous     token.Token
+	hadError     bool
+	panicMode    bool
+	currentClass *ClassCompiler
+	loxerror     *loxerrors.LoxErrors
+}
+
+type Compiler struct {
+	parser     *Parser
+	enclosing  *Compiler
+	function   *ObjFunction
+	typ        FunctionType
+	locals     []Local
+	upvalues   []Upvalue
+	scopeDepth int
+	loop       *Loop
+}
+
+// Compile translates a token stream into the top-level script function. It
+// returns nil if any compile error was reported.
+func Compile(tokens []token.Token, loxerror *loxerrors.LoxErrors) *ObjFunction {
+	parser := &Parser{tokens: tokens, loxerror: loxerror}
+	compiler := newCompiler(parser, nil, TYPE_SCRIPT, "")
+
+	parser.advance()
+	for !compiler.match(token.EOF) {
+		compiler.declaration()
+	}
+
+	function := compiler.endCompiler()
+	if parser.hadError {
+		return nil
+	}
+	return function
+}
+
+func newCompiler(parser *Parser, enclosing *Compiler, typ FunctionType, name string) *Compiler {
+	c := &Compiler{parser: parser, enclosing: enclosing, function: NewFunction(), typ: typ}
+	c.function.Name = name
+
+	// Slot zero holds the called closure, or the receiver inside methods.
+	slot := Local{depth: 0}
+	if typ != TYPE_FUNCTION {
+		slot.name.Lexeme = "this"
+	}
+	c.locals = append(c.locals, slot)
+	return c
+}
+
+// parser
+
+func (p *Parser) advance() {
+	p.previous = p.current
+	if p.next < len(p.tokens) {
+		p.current = p.tokens[p.next]
+		p.next++
+	}
+}
+
+func (p *Parser) errorAt(tok token.Token, message string) {
+	p.report(p.loxerror.TokenError, tok, message)
+}
+
+func (p *Parser) report(reporter func(token.Token, string), tok token.Token, message string) {
+	if p.panicMode {
+		return
+	}
+	p.panicMode = true
+	reporter(tok, message)
+	p.hadError = true
+}
+
+func (p *Parser) error(message string) {
+	p.errorAt(p.previous, message)
+}
+
+// resolveError is for the checks the tree-walker leaves to its resolver.
+func (p *Parser) resolveError(message string) {
+	p.report(p.loxerror.ResolveError, p.previous, message)
+}
+
+func (p *Parser) errorAtCurrent(message string) {
+	p.errorAt(p.current, message)
+}
+
+func (c *Compiler) consume(typ token.TokenType, message string) {
+	if c.parser.current.Typ == typ {
+		c.parser.advance()
+		return
+	}
+	c.parser.errorAtCurrent(message)
+}
+
+func (c *Compiler) check(typ token.TokenType) bool {
+	return c.parser.current.Typ == typ
+}
+
+func (c *Compiler) match(typ token.TokenType) bool {
+	if !c.check(typ) {
+		return false
+	}
+	c.parser.advance()
+	return true
+}
+
+// emitters
+
+func (c *Compiler) currentChunk() *Chunk {
+	return c.function.Chunk
+}
+
+func (c *Compiler) emitByte(b byte) {
+	c.currentChunk().Write(b, c.parser.previous.Line)
+}
+
+func (c *Compiler) emitOp(op Opcode) {
+	c.emitByte(byte(op))
+}
+
+func (c *Compiler) emitOps(op Opcode, operand byte) {
+	c.emitOp(op)
+	c.emitByte(operand)
+}
+
+func (c *Compiler) emitLoop(loopStart int) {
+	c.emitOp(OP_LOOP)
+
+	offset := c.currentChunk().Count() - loopStart + 2
+	if offset > math.MaxUint16 {
+		c.parser.error("Loop body too large.")
+	}
+
+	c.emitByte(byte(offset >> 8 & 0xff))
+	c.emitByte(byte(offset & 0xff))
+}
+
+func (c *Compiler) emitJump(op Opcode) int {
+	c.emitOp(op)
+	c.emitByte(0xff)
+	c.emitByte(0xff)
+	return c.currentChunk().Count() - 2
+}
+
+func (c *Compiler) emitReturn() {
+	if c.typ == TYPE_INITIALIZER {
+		c.emitOps(OP_GET_LOCAL, 0)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+	c.emitOp(OP_RETURN)
+}
+
+func (c *Compiler) makeConstant(value Value) byte {
+	constant := c.currentChunk().AddConstant(value)
+	if constant > math.MaxUint8 {
+		c.parser.error("Too many constants in one chunk.")
+		return 0
+	}
+	return byte(constant)
+}
+
+func (c *Compiler) emitConstant(value Value) {
+	c.emitOps(OP_CONSTANT, c.makeConstant(value))
+}
+
+func (c *Compiler) patchJump(offset int) {
+	// -2 to adjust for the bytecode for the jump offset itself.
+	jump := c.currentChunk().Count() - offset - 2
+	if jump > math.MaxUint16 {
+		c.parser.error("Too much code to jump over.")
+	}
+
+	c.currentChunk().Code[offset] = byte(jump >> 8 & 0xff)
+	c.currentChunk().Code[offset+1] = byte(jump & 0xff)
+}
+
+func (c *Compiler) endCompiler() *ObjFunction {
+	c.emitReturn()
+	return c.function
+}
+
+// scopes and variables
+
+func (c *Compiler) beginScope() {
+	c.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	c.scopeDepth--
+
+	for len(c.locals) > 0 && c.locals[len(c.locals)-1].depth > c.scopeDepth {
+		if c.locals[len(c.locals)-1].isCaptured {
+			c.emitOp(OP_CLOSE_UPVALUE)
+		} else {
+			c.emitOp(OP_POP)
+		}
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+}
+
+// discardLocals emits the code to drop the locals deeper than depth
+// without forgetting them, for jumping out of their scopes.
+func (c *Compiler) discardLocals(depth int) {
+	for i := len(c.locals) - 1; i >= 0 && c.locals[i].depth > depth; i-- {
+		if c.locals[i].isCaptured {
+			c.emitOp(OP_CLOSE_UPVALUE)
+		} else {
+			c.emitOp(OP_POP)
+		}
+	}
+}
+
+func (c *Compiler) identifierConstant(name token.Token) byte {
+	return c.makeConstant(name.Lexeme)
+}
+
+func (c *Compiler) resolveLocal(name token.Token) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		local := c.locals[i]
+		if name.Lexeme == local.name.Lexeme {
+			if local.depth == -1 {
+				c.parser.resolveError("Can't read local variable in its own initializer.")
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) addUpvalue(index byte, isLocal bool) int {
+	for i, upvalue := range c.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return i
+		}
+	}
+
+	if len(c.upvalues) == UINT8_COUNT {
+		c.parser.error("Too many closure variables in function.")
+		return 0
+	}
+
+	c.upvalues = append(c.upvalues, Upvalue{index, isLocal})
+	c.function.UpvalueCount++
+	return len(c.upvalues) - 1
+}
+
+func (c *Compiler) resolveUpvalue(name token.Token) int {
+	if c.enclosing == nil {
+		return -1
+	}
+
+	if local := c.enclosing.resolveLocal(name); local != -1 {
+		c.enclosing.locals[local].isCaptured = true
+		return c.addUpvalue(byte(local), true)
+	}
+
+	if upvalue := c.enclosing.resolveUpvalue(name); upvalue != -1 {
+		return c.addUpvalue(byte(upvalue), false)
+	}
+
+	return -1
+}
+
+func (c *Compiler) addLocal(name token.Token) {
+	if len(c.locals) == UINT8_COUNT {
+		c.parser.error("Too many local variables in function.")
+		return
+	}
+	c.locals = append(c.locals, Local{name: name, depth: -1})
+}
+
+func (c *Compiler) declareVariable() {
+	if c.scopeDepth == 0 {
+		return
+	}
+
+	name := c.parser.previous
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		local := c.locals[i]
+		if local.depth != -1 && local.depth < c.scopeDepth {
+			break
+		}
+
+		if name.Lexeme == local.name.Lexeme {
+			c.parser.resolveError("Already a variable with this name in this scope.")
+		}
+	}
+
+	c.addLocal(name)
+}
+
+func (c *Compiler) parseVariable(message string) byte {
+	c.consume(token.IDENTIFIER, message)
+
+	c.declareVariable()
+	if c.scopeDepth > 0 {
+		return 0
+	}
+
+	return c.identifierConstant(c.parser.previous)
+}
+
+func (c *Compiler) markInitialized() {
+	if c.scopeDepth == 0 {
+		return
+	}
+	c.locals[len(c.locals)-1].depth = c.scopeDepth
+}
+
+func (c *Compiler) defineVariable(global byte) {
+	if c.scopeDepth > 0 {
+		c.markInitialized()
+		return
+	}
+
+	c.emitOps(OP_DEFINE_GLOBAL, global)
+}
+
+func (c *Compiler) argumentList() byte {
+	argCount := 0
+	if !c.check(token.RIGHT_PAREN) {
+		for {
+			c.expression()
+			if argCount == 255 {
+				c.parser.error("Can't have more than 255 arguments.")
+			}
+			argCount++
+			if !c.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	c.consume(token.RIGHT_PAREN, "Expect ')' after arguments.")
+	return byte(argCount)
+}
+
+// expressions
+
+func (c *Compiler) and(canAssign bool) {
+	endJump := c.emitJump(OP_JUMP_IF_FALSE)
+
+	c.emitOp(OP_POP)
+	c.parsePrecedence(PREC_AND)
+
+	c.patchJump(endJump)
+}
+
+func (c *Compiler) or(canAssign bool) {
+	elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+	endJump := c.emitJump(OP_JUMP)
+
+	c.patchJump(elseJump)
+	c.emitOp(OP_POP)
+
+	c.parsePrecedence(PREC_OR)
+	c.patchJump(endJump)
+}
+
+func (c *Compiler) binary(canAssign bool) {
+	operatorType := c.parser.previous.Typ
+	rule := getRule(operatorType)
+	c.parsePrecedence(rule.precedence + 1)
+
+	switch operatorType {
+	case token.BANG_EQUAL:
+		c.emitOp(OP_EQUAL)
+		c.emitOp(OP_NOT)
+	case token.EQUAL_EQUAL:
+		c.emitOp(OP_EQUAL)
+	case token.GREATER:
+		c.emitOp(OP_GREATER)
+	case token.GREATER_EQUAL:
+		c.emitOp(OP_LESS)
+		c.emitOp(OP_NOT)
+	case token.LESS:
+		c.emitOp(OP_LESS)
+	case token.LESS_EQUAL:
+		c.emitOp(OP_GREATER)
+		c.emitOp(OP_NOT)
+	case token.PLUS:
+		c.emitOp(OP_ADD)
+	case token.MINUS:
+		c.emitOp(OP_SUBTRACT)
+	case token.STAR:
+		c.emitOp(OP_MULTIPLY)
+	case token.SLASH:
+		c.emitOp(OP_DIVIDE)
+	}
+}
+
+func (c *Compiler) call(canAssign bool) {
+	argCount := c.argumentList()
+	c.emitOps(OP_CALL, argCount)
+}
+
+func (c *Compiler) dot(canAssign bool) {
+	c.consume(token.IDENTIFIER, "Expect property name after '.'.")
+	name := c.identifierConstant(c.parser.previous)
+
+	if canAssign && c.match(token.EQUAL) {
+		c.expression()
+		c.emitOps(OP_SET_PROPERTY, name)
+	} else if c.match(token.LEFT_PAREN) {
+		argCount := c.argumentList()
+		c.emitOps(OP_INVOKE, name)
+		c.emitByte(argCount)
+	} else {
+		c.emitOps(OP_GET_PROPERTY, name)
+	}
+}
+
+func (c *Compiler) literal(canAssign bool) {
+	switch c.parser.previous.Typ {
+	case token.FALSE:
+		c.emitOp(OP_FALSE)
+	case token.NIL:
+		c.emitOp(OP_NIL)
+	case token.TRUE:
+		c.emitOp(OP_TRUE)
+	}
+}
+
+func (c *Compiler) grouping(canAssign bool) {
+	c.expression()
+	c.consume(token.RIGHT_PAREN, "Expect ')' after expression.")
+}
+
+func (c *Compiler) number(canAssign bool) {
+	c.emitConstant(c.parser.previous.Literal.(float64))
+}
+
+func (c *Compiler) str(canAssign bool) {
+	c.emitConstant(c.parser.previous.Literal.(string))
+}
+
+// interpolation rejects an interpolated string, which the scanner shares
+// with the tree-walker but this engine does not implement yet.
+func (c *Compiler) interpolation(canAssign bool) {
+	c.parser.error("String interpolation is not supported by this engine.")
+}
+
+func (c *Compiler) namedVariable(name token.Token, canAssign bool) {
+	var getOp, setOp Opcode
+	arg := c.resolveLocal(name)
+	if arg != -1 {
+		getOp = OP_GET_LOCAL
+		setOp = OP_SET_LOCAL
+	} else if arg = c.resolveUpvalue(name); arg != -1 {
+		getOp = OP_GET_UPVALUE
+		setOp = OP_SET_UPVALUE
+	} else {
+		arg = int(c.identifierConstant(name))
+		getOp = OP_GET_GLOBAL
+		setOp = OP_SET_GLOBAL
+	}
+
+	if canAssign && c.match(token.EQUAL) {
+		c.expression()
+		c.emitOps(setOp, byte(arg))
+	} else {
+		c.emitOps(getOp, byte(arg))
+	}
+}
+
+func (c *Compiler) variable(canAssign bool) {
+	c.namedVariable(c.parser.previous, canAssign)
+}
+
+func (c *Compiler) this(canAssign bool) {
+	if c.parser.currentClass == nil {
+		c.parser.resolveError("Can't use 'this' outside of a class.")
+		return
+	}
+	c.variable(false)
+}
+
+func (c *Compiler) lambda(canAssign bool) {
+	c.compileFunction(TYPE_FUNCTION, "anonymous", "'fun'")
+}
+
+func (c *Compiler) unary(canAssign bool) {
+	operatorType := c.parser.previous.Typ
+
+	c.parsePrecedence(PREC_UNARY)
+
+	switch operatorType {
+	case token.BANG:
+		c.emitOp(OP_NOT)
+	case token.MINUS:
+		c.emitOp(OP_NEGATE)
+	}
+}
+
+func (c *Compiler) parsePrecedence(precedence Precedence) {
+	c.parser.advance()
+	prefixRule := getRule(c.parser.previous.Typ).prefix
+	if prefixRule == nil {
+		c.parser.error("Expect expression.")
+		return
+	}
+
+	canAssign := precedence <= PREC_ASSIGNMENT
+	prefixRule(c, canAssign)
+
+	for precedence <= getRule(c.parser.current.Typ).precedence {
+		c.parser.advance()
+		infixRule := getRule(c.parser.previous.Typ).infix
+		infixRule(c, canAssign)
+	}
+
+	if canAssign && c.match(token.EQUAL) {
+		c.parser.error("Invalid assignment target.")
+	}
+}
+
+func (c *Compiler) expression() {
+	c.parsePrecedence(PREC_ASSIGNMENT)
+}
+
+// statements
+
+func (c *Compiler) block() {
+	for !c.check(token.RIGHT_BRACE) && !c.check(token.EOF) {
+		c.declaration()
+	}
+
+	c.consume(token.RIGHT_BRACE, "Expect '}' after block.")
+}
+
+// compileFunction compiles the parameter list and body of a function and
+// emits the closure for it. after names what precedes the '(' for errors.
+func (c *Compiler) compileFunction(typ FunctionType, name, after string) {
+	compiler := newCompiler(c.parser, c, typ, name)
+	compiler.beginScope()
+
+	compiler.consume(token.LEFT_PAREN, "Expect '(' after "+after+".")
+	if !compiler.check(token.RIGHT_PAREN) {
+		for {
+			compiler.function.Arity++
+			if compiler.function.Arity > 255 {
+				c.parser.errorAtCurrent("Can't have more than 255 parameters.")
+			}
+			constant := compiler.parseVariable("Expect parameter name.")
+			compiler.defineVariable(constant)
+			if !compiler.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	compiler.consume(token.RIGHT_PAREN, "Expect ')' after parameters.")
+	compiler.consume(token.LEFT_BRACE, "Expect '{' before function body.")
+	compiler.block()
+
+	function := compiler.endCompiler()
+	c.emitOps(OP_CLOSURE, c.makeConstant(function))
+
+	for _, upvalue := range compiler.upvalues {
+		if upvalue.isLocal {
+			c.emitByte(1)
+		} else {
+			c.emitByte(0)
+		}
+		c.emitByte(upvalue.index)
+	}
+}
+
+func (c *Compiler) method() {
+	c.consume(token.IDENTIFIER, "Expect method name.")
+	name := c.parser.previous.Lexeme
+	constant := c.identifierConstant(c.parser.previous)
+
+	typ := TYPE_METHOD
+	if name == "init" {
+		typ = TYPE_INITIALIZER
+	}
+
+	c.compileFunction(typ, name, "method name")
+	c.emitOps(OP_METHOD, constant)
+}
+
+func (c *Compiler) classDeclaration() {
+	c.consume(token.IDENTIFIER, "Expect class name.")
+	className := c.parser.previous
+	nameConstant := c.identifierConstant(c.parser.previous)
+	c.declareVariable()
+
+	c.emitOps(OP_CLASS, nameConstant)
+	c.defineVariable(nameConstant)
+
+	classCompiler := &ClassCompiler{enclosing: c.parser.currentClass}
+	c.parser.currentClass = classCompiler
+
+	c.namedVariable(className, false)
+	c.consume(token.LEFT_BRACE, "Expect '{' before class body.")
+	for !c.check(token.RIGHT_BRACE) && !c.check(token.EOF) {
+		c.method()
+	}
+	c.consume(token.RIGHT_BRACE, "Expect '}' after class body.")
+	c.emitOp(OP_POP)
+
+	c.parser.currentClass = classCompiler.enclosing
+}
+
+func (c *Compiler) funDeclaration() {
+	global := c.parseVariable("Expect function name.")
+	c.markInitialized()
+	c.compileFunction(TYPE_FUNCTION, c.parser.previous.Lexeme, "function name")
+	c.defineVariable(global)
+}
+
+func (c *Compiler) varDeclaration() {
+	global := c.parseVariable("Expect variable name.")
+
+	if c.match(token.EQUAL) {
+		c.expression()
+	} else {
+		c.emitOp(OP_NIL)
+	}
+	c.consume(token.SEMICOLON, "Expect ';' after variable declaration.")
+
+	c.defineVariable(global)
+}
+
+func (c *Compiler) expressionStatement() {
+	c.expression()
+	c.consume(token.SEMICOLON, "Expect ';' after expression.")
+	c.emitOp(OP_POP)
+}
+
+func (c *Compiler) forStatement() {
+	c.beginScope()
+	c.consume(token.LEFT_PAREN, "Expect '(' after 'for'.")
+	if c.match(token.SEMICOLON) {
+		// No initializer.
+	} else if c.match(token.VAR) {
+		c.varDeclaration()
+	} else {
+		c.expressionStatement()
+	}
+
+	loopStart := c.currentChunk().Count()
+	exitJump := -1
+	if !c.match(token.SEMICOLON) {
+		c.expression()
+		c.consume(token.SEMICOLON, "Expect ';' after loop condition.")
+
+		// Jump out of the loop if the condition is false.
+		exitJump = c.emitJump(OP_JUMP_IF_FALSE)
+		c.emitOp(OP_POP)
+	}
+
+	if !c.match(token.RIGHT_PAREN) {
+		bodyJump := c.emitJump(OP_JUMP)
+		incrementStart := c.currentChunk().Count()
+		c.expression()
+		c.emitOp(OP_POP)
+		c.consume(token.RIGHT_PAREN, "Expect ')' after for clauses.")
+
+		c.emitLoop(loopStart)
+		loopStart = incrementStart
+		c.patchJump(bodyJump)
+	}
+
+	loop := c.beginLoop(loopStart)
+	c.statement()
+	c.emitLoop(loopStart)
+
+	if exitJump != -1 {
+		c.patchJump(exitJump)
+		c.emitOp(OP_POP)
+	}
+	c.endLoop(loop)
+
+	c.endScope()
+}
+
+func (c *Compiler) beginLoop(start int) *Loop {
+	c.loop = &Loop{enclosing: c.loop, start: start, scopeDepth: c.scopeDepth}
+	return c.loop
+}
+
+// endLoop sends the loop's break statements to the current position.
+func (c *Compiler) endLoop(loop *Loop) {
+	for _, jump := range loop.breaks {
+		c.patchJump(jump)
+	}
+	c.loop = loop.enclosing
+}
+
+func (c *Compiler) breakStatement() {
+	if c.loop == nil {
+		c.parser.error("Can't use 'break' outside of a loop.")
+	}
+	c.consume(token.SEMICOLON, "Expect ';' after 'break'.")
+	if c.loop != nil {
+		c.discardLocals(c.loop.scopeDepth)
+		c.loop.breaks = append(c.loop.breaks, c.emitJump(OP_JUMP))
+	}
+}
+
+func (c *Compiler) continueStatement() {
+	if c.loop == nil {
+		c.parser.error("Can't use 'continue' outside of a loop.")
+	}
+	c.consume(token.SEMICOLON, "Expect ';' after 'continue'.")
+	if c.loop != nil {
+		c.discardLocals(c.loop.scopeDepth)
+		c.emitLoop(c.loop.start)
+	}
+}
+
+func (c *Compiler) ifStatement() {
+	c.consume(token.LEFT_PAREN, "Expect '(' after 'if'.")
+	c.expression()
+	c.consume(token.RIGHT_PAREN, "Expect ')' after condition.")
+
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.statement()
+
+	elseJump := c.emitJump(OP_JUMP)
+
+	c.patchJump(thenJump)
+	c.emitOp(OP_POP)
+
+	if c.match(token.ELSE) {
+		c.statement()
+	}
+	c.patchJump(elseJump)
+}
+
+func (c *Compiler) printStatement() {
+	c.expression()
+	c.consume(token.SEMICOLON, "Expect ';' after value.")
+	c.emitOp(OP_PRINT)
+}
+
+func (c *Compiler) returnStatement() {
+	if c.typ == TYPE_SCRIPT {
+		c.parser.resolveError("Can't return from top-level code.")
+	}
+
+	if c.match(token.SEMICOLON) {
+		c.emitReturn()
+	} else {
+		if c.typ == TYPE_INITIALIZER {
+			c.parser.resolveError("Can't return a value from an initializer.")
+		}
+
+		c.expression()
+		c.consume(token.SEMICOLON, "Expect ';' after return value.")
+		c.emitOp(OP_RETURN)
+	}
+}
+
+func (c *Compiler) whileStatement() {
+	loopStart := c.currentChunk().Count()
+	c.consume(token.LEFT_PAREN, "Expect '(' after 'while'.")
+	c.expression()
+	c.consume(token.RIGHT_PAREN, "Expect ')' after condition.")
+
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	loop := c.beginLoop(loopStart)
+	c.statement()
+	c.emitLoop(loopStart)
+
+	c.patchJump(exitJump)
+	c.emitOp(OP_POP)
+	c.endLoop(loop)
+}
+
+func (c *Compiler) synchronize() {
+	c.parser.panicMode = false
+
+	for c.parser.current.Typ != token.EOF {
+		if c.parser.previous.Typ == token.SEMICOLON {
+			return
+		}
+		switch c.parser.current.Typ {
+		case token.CLASS, token.FUN, token.VAR, token.FOR, token.IF,
+			token.WHILE, token.PRINT, token.RETURN:
+			return
+		}
+
+		c.parser.advance()
+	}
+}
+
+func (c *Compiler) declaration() {
+	if c.match(token.CLASS) {
+		c.classDeclaration()
+	} else if c.check(token.FUN) && c.checkNext(token.IDENTIFIER) {
+		c.parser.advance()
+		c.funDeclaration()
+	} else if c.match(token.VAR) {
+		c.varDeclaration()
+	} else {
+		c.statement()
+	}
+
+	if c.parser.panicMode {
+		c.synchronize()
+	}
+}
+
+func (c *Compiler) checkNext(typ token.TokenType) bool {
+	if c.parser.next >= len(c.parser.tokens) {
+		return false
+	}
+	return c.parser.tokens[c.parser.next].Typ == typ
+}
+
+func (c *Compiler) statement() {
+	if c.match(token.PRINT) {
+		c.printStatement()
+	} else if c.match(token.FOR) {
+		c.forStatement()
+	} else if c.match(token.IF) {
+		c.ifStatement()
+	} else if c.match(token.RETURN) {
+		c.returnStatement()
+	} else if c.match(token.WHILE) {
+		c.whileStatement()
+	} else if c.match(token.BREAK) {
+		c.breakStatement()
+	} else if c.match(token.CONTINUE) {
+		c.continueStatement()
+	} else if c.match(token.LEFT_BRACE) {
+		c.beginScope()
+		c.block()
+		c.endScope()
+	} else {
+		c.expressionStatement()
+	}
+}
@@ -0,0 +1,103 @@
+package bytecode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Disassemble renders chunk as a listing headed "== name ==". The chunks of
+// function constants follow depth-first, in constant pool order, each headed
+// "== fnname/arity ==", so that Assemble can rebuild the whole tree.
+func Disassemble(chunk *Chunk, name string) string {
+	var b strings.Builder
+	disassembleChunk(&b, chunk, name)
+	return b.String()
+}
+
+func disassembleChunk(b *strings.Builder, chunk *Chunk, name string) {
+	fmt.Fprintf(b, "== %s ==\n", name)
+
+	for offset := 0; offset < len(chunk.Code); {
+		var text string
+		text, offset = DisassembleInstruction(chunk, offset)
+		b.WriteString(text)
+	}
+
+	for _, constant := range chunk.Constants {
+		if function, ok := constant.(*ObjFunction); ok {
+			disassembleChunk(b, function.Chunk, fmt.Sprintf("%s/%d", function.Name, function.Arity))
+		}
+	}
+}
+
+// DisassembleInstruction renders the instruction at offset and returns the
+// offset of the next one.
+func DisassembleInstruction(chunk *Chunk, offset int) (string, int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%04d ", offset)
+	if offset > 0 && chunk.GetLine(offset) == chunk.GetLine(offset-1) {
+		b.WriteString("   | ")
+	} else {
+		fmt.Fprintf(&b, "%4d ", chunk.GetLine(offset))
+	}
+
+	op := Opcode(chunk.Code[offset])
+	switch op {
+	case OP_CONSTANT, OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL,
+		OP_GET_PROPERTY, OP_SET_PROPERTY, OP_CLASS, OP_METHOD:
+		constant := chunk.Code[offset+1]
+		fmt.Fprintf(&b, "%-16s %4d %s\n", op, constant, formatConstant(chunk.Constants[constant]))
+		return b.String(), offset + 2
+	case OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_CALL:
+		fmt.Fprintf(&b, "%-16s %4d\n", op, chunk.Code[offset+1])
+		return b.String(), offset + 2
+	case OP_JUMP, OP_JUMP_IF_FALSE, OP_LOOP:
+		jump := int(chunk.Code[offset+1])<<8 | int(chunk.Code[offset+2])
+		sign := 1
+		if op == OP_LOOP {
+			sign = -1
+		}
+		fmt.Fprintf(&b, "%-16s %4d -> %d\n", op, offset, offset+3+sign*jump)
+		return b.String(), offset + 3
+	case OP_INVOKE:
+		constant := chunk.Code[offset+1]
+		argCount := chunk.Code[offset+2]
+		fmt.Fprintf(&b, "%-16s (%d args) %4d %s\n", op, argCount, constant, formatConstant(chunk.Constants[constant]))
+		return b.String(), offset + 3
+	case OP_CLOSURE:
+		constant := chunk.Code[offset+1]
+		offset += 2
+		fmt.Fprintf(&b, "%-16s %4d %s\n", op, constant, formatConstant(chunk.Constants[constant]))
+
+		function := chunk.Constants[constant].(*ObjFunction)
+		for j := 0; j < function.UpvalueCount; j++ {
+			kind := "upvalue"
+			if chunk.Code[offset] == 1 {
+				kind = "local"
+			}
+			fmt.Fprintf(&b, "%04d      |                     %s %d\n", offset, kind, chunk.Code[offset+1])
+			offset += 2
+		}
+		return b.String(), offset
+	}
+
+	if int(op) >= len(opcodeNames) {
+		fmt.Fprintf(&b, "Unknown opcode %d\n", byte(op))
+	} else {
+		fmt.Fprintf(&b, "%s\n", op)
+	}
+	return b.String(), offset + 1
+}
+
+// formatConstant renders a constant so that parseConstant can read it back:
+// strings are quoted to keep them apart from numbers.
+func formatConstant(value Value) string {
+	switch value := value.(type) {
+	case string:
+		return strconv.Quote(value)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	return Stringify(value)
+}
@@ -0,0 +1,52 @@
+package bytecode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"lox/engine"
+	"os"
+)
+
+const PROMPT = "> "
+
+func init() {
+	engine.Register("bytecode", func() engine.Engine { return New() })
+}
+
+func (vm *VM) RunSource(source string) int {
+	switch vm.Interpret(source) {
+	case INTERPRET_COMPILE_ERROR:
+		return engine.EXIT_DATA_ERROR
+	case INTERPRET_RUNTIME_ERROR:
+		return engine.EXIT_SOFTWARE
+	}
+	return engine.EXIT_OK
+}
+
+func (vm *VM) RunFile(filename string) int {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(vm.stderr, "Could not open file %q: %v\n", filename, err)
+		return engine.EXIT_IO_ERROR
+	}
+	return vm.RunSource(string(data))
+}
+
+// RunPrompt keeps globals between lines, so later lines can use earlier
+// declarations. Printed values go to out.
+func (vm *VM) RunPrompt(in io.Reader, out io.Writer) {
+	stdout := vm.stdout
+	vm.stdout = out
+	defer func() { vm.stdout = stdout }()
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, PROMPT)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		vm.Interpret(scanner.Text())
+	}
+}
@@ -1,13 +1,150 @@
 package bytecode
 
-type Opcode int
+import "fmt"
+
+type Opcode byte
 
 const (
-	OP_RETURN = Opcode(iota)
+	OP_CONSTANT = Opcode(iota)
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_GET_GLOBAL
+	OP_DEFINE_GLOBAL
+	OP_SET_GLOBAL
+	OP_GET_UPVALUE
+	OP_SET_UPVALUE
+	OP_GET_PROPERTY
+	OP_SET_PROPERTY
+	OP_EQUAL
+	OP_GREATER
+	OP_LESS
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NOT
+	OP_NEGATE
+	OP_PRINT
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+	OP_LOOP
+	OP_CALL
+	OP_INVOKE
+	OP_CLOSURE
+	OP_CLOSE_UPVALUE
+	OP_RETURN
+	OP_CLASS
+	OP_METHOD
 )
 
+var opcodeNames = [...]string{
+	"OP_CONSTANT",
+	"OP_NIL",
+	"OP_TRUE",
+	"OP_FALSE",
+	"OP_POP",
+	"OP_GET_LOCAL",
+	"OP_SET_LOCAL",
+	"OP_GET_GLOBAL",
+	"OP_DEFINE_GLOBAL",
+	"OP_SET_GLOBAL",
+	"OP_GET_UPVALUE",
+	"OP_SET_UPVALUE",
+	"OP_GET_PROPERTY",
+	"OP_SET_PROPERTY",
+	"OP_EQUAL",
+	"OP_GREATER",
+	"OP_LESS",
+	"OP_ADD",
+	"OP_SUBTRACT",
+	"OP_MULTIPLY",
+	"OP_DIVIDE",
+	"OP_NOT",
+	"OP_NEGATE",
+	"OP_PRINT",
+	"OP_JUMP",
+	"OP_JUMP_IF_FALSE",
+	"OP_LOOP",
+	"OP_CALL",
+	"OP_INVOKE",
+	"OP_CLOSURE",
+	"OP_CLOSE_UPVALUE",
+	"OP_RETURN",
+	"OP_CLASS",
+	"OP_METHOD",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return fmt.Sprintf("OP_UNKNOWN(%d)", byte(op))
+}
+
+func LookupOpcode(name string) (Opcode, bool) {
+	for i, opName := range opcodeNames {
+		if opName == name {
+			return Opcode(i), true
+		}
+	}
+	return 0, false
+}
+
+// LineStart marks the first byte of a run of instructions on the same line.
+type LineStart struct {
+	Offset int
+	Line   int
+}
+
 type Chunk struct {
-	Count    int
-	Capacity int
-	Code     Opcode
+	Code      []byte
+	Lines     []LineStart
+	Constants []Value
+}
+
+func NewChunk() *Chunk {
+	return &Chunk{}
+}
+
+func (c *Chunk) Write(b byte, line int) {
+	c.Code = append(c.Code, b)
+	if len(c.Lines) > 0 && c.Lines[len(c.Lines)-1].Line == line {
+		return
+	}
+	c.Lines = append(c.Lines, LineStart{len(c.Code) - 1, line})
+}
+
+// GetLine returns the source line of the instruction byte at offset.
+func (c *Chunk) GetLine(offset int) int {
+	if len(c.Lines) == 0 {
+		return 0
+	}
+
+	lo, hi := 0, len(c.Lines)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if c.Lines[mid].Offset <= offset {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return c.Lines[lo].Line
+}
+
+func (c *Chunk) WriteOp(op Opcode, line int) {
+	c.Write(byte(op), line)
+}
+
+func (c *Chunk) AddConstant(value Value) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+func (c *Chunk) Count() int {
+	return len(c.Code)
 }
@@ -0,0 +1,117 @@
+package bytecode
+
+import (
+	"fmt"
+	"lox/treewalk/astprinter"
+)
+
+// Value is any Lox value: nil, bool, float64, string or one of the Obj types.
+type Value any
+
+type NativeFn func(args []Value) Value
+
+type ObjFunction struct {
+	Arity        int
+	UpvalueCount int
+	Chunk        *Chunk
+	Name         string
+}
+
+func NewFunction() *ObjFunction {
+	return &ObjFunction{Chunk: NewChunk()}
+}
+
+func (f *ObjFunction) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return "<fn " + f.Name + ">"
+}
+
+type ObjNative struct {
+	Name     string
+	Function NativeFn
+}
+
+func (n *ObjNative) String() string {
+	return "<native fn>"
+}
+
+type ObjUpvalue struct {
+	location *Value
+	closed   Value
+	slot     int
+	next     *ObjUpvalue
+}
+
+type ObjClosure struct {
+	Function *ObjFunction
+	Upvalues []*ObjUpvalue
+}
+
+func NewClosure(function *ObjFunction) *ObjClosure {
+	return &ObjClosure{Function: function, Upvalues: make([]*ObjUpvalue, function.UpvalueCount)}
+}
+
+func (c *ObjClosure) String() string {
+	return c.Function.String()
+}
+
+type ObjClass struct {
+	Name    string
+	Methods map[string]*ObjClosure
+}
+
+func NewClass(name string) *ObjClass {
+	return &ObjClass{Name: name, Methods: make(map[string]*ObjClosure)}
+}
+
+func (c *ObjClass) String() string {
+	return c.Name
+}
+
+type ObjInstance struct {
+	Class  *ObjClass
+	Fields map[string]Value
+}
+
+func NewInstance(class *ObjClass) *ObjInstance {
+	return &ObjInstance{Class: class, Fields: make(map[string]Value)}
+}
+
+func (i *ObjInstance) String() string {
+	return i.Class.Name + " instance"
+}
+
+type ObjBoundMethod struct {
+	Receiver Value
+	Method   *ObjClosure
+}
+
+func (b *ObjBoundMethod) String() string {
+	return b.Method.Function.String()
+}
+
+func isFalsey(value Value) bool {
+	if value == nil {
+		return true
+	}
+	if b, ok := value.(bool); ok {
+		return !b
+	}
+	return false
+}
+
+func valuesEqual(a, b Value) bool {
+	return a == b
+}
+
+func Stringify(value Value) string {
+	if value == nil {
+		return "nil"
+	}
+	if n, ok := value.(float64); ok {
+		return astprinter.FormatNumber(n)
+	}
+	return fmt.Sprint(value)
+}
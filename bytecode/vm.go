@@ -0,0 +1,483 @@
+package bytecode
+
+import (
+	"fmt"
+	"io"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/scanner"
+	"os"
+	"time"
+)
+
+const (
+	FRAMES_MAX = 64
+	STACK_MAX  = FRAMES_MAX * UINT8_COUNT
+)
+
+type InterpretResult int
+
+const (
+	INTERPRET_OK InterpretResult = iota
+	INTERPRET_COMPILE_ERROR
+	INTERPRET_RUNTIME_ERROR
+)
+
+type CallFrame struct {
+	closure *ObjClosure
+	ip      int
+	slots   int
+}
+
+type VM struct {
+	frames       [FRAMES_MAX]CallFrame
+	frameCount   int
+	stack        [STACK_MAX]Value
+	stackTop     int
+	globals      map[string]Value
+	openUpvalues *ObjUpvalue
+	diagnostics  loxerrors.Sink
+	loxerror     *loxerrors.LoxErrors
+	stdout       io.Writer
+	stderr       io.Writer
+}
+
+func New() *VM {
+	vm := &VM{globals: make(map[string]Value), loxerror: loxerrors.New(), stdout: os.Stdout, stderr: os.Stderr}
+	vm.defineNative("clock", clockNative)
+	return vm
+}
+
+func clockNative(args []Value) Value {
+	return float64(time.Now().UnixNano()) / float64(time.Second)
+}
+
+// SetDiagnostics sends compile and runtime errors to sink instead of
+// printing them to stderr.
+func (vm *VM) SetDiagnostics(sink loxerrors.Sink) {
+	vm.diagnostics = sink
+}
+
+// SetOutput sends the output of print statements to stdout and error
+// reports to stderr.
+func (vm *VM) SetOutput(stdout, stderr io.Writer) {
+	vm.stdout = stdout
+	vm.stderr = stderr
+}
+
+// CompileSource scans and compiles source, returning nil on any error.
+// Errors are printed to stderr.
+func CompileSource(source string) *ObjFunction {
+	return compile(source, loxerrors.NewWithSink(loxerrors.NewTextSink(os.Stderr, source)))
+}
+
+func compile(source string, loxerror *loxerrors.LoxErrors) *ObjFunction {
+	tokens := scanner.New(source, loxerror).ScanTokens()
+	function := Compile(tokens, loxerror)
+	if loxerror.HadError {
+		return nil
+	}
+	return function
+}
+
+func (vm *VM) Interpret(source string) InterpretResult {
+	sink := vm.diagnostics
+	if sink == nil {
+		sink = loxerrors.NewTextSink(vm.stderr, source)
+	}
+	vm.loxerror = loxerrors.NewWithSink(sink)
+
+	function := compile(source, vm.loxerror)
+	if function == nil {
+		return INTERPRET_COMPILE_ERROR
+	}
+	return vm.Run(function)
+}
+
+// Run executes an already compiled top-level function.
+func (vm *VM) Run(function *ObjFunction) InterpretResult {
+	closure := NewClosure(function)
+	vm.push(closure)
+	vm.call(closure, 0)
+
+	return vm.run()
+}
+
+func (vm *VM) resetStack() {
+	vm.stackTop = 0
+	vm.frameCount = 0
+	vm.openUpvalues = nil
+}
+
+// runtimeError reports the error with the call stack as notes, innermost
+// frame first.
+func (vm *VM) runtimeError(format string, args ...any) {
+	diagnostic := loxerrors.Diagnostic{
+		Code:    loxerrors.CODE_RUNTIME,
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	for i := vm.frameCount - 1; i >= 0; i-- {
+		frame := &vm.frames[i]
+		function := frame.closure.Function
+		// ip has already moved past the failing instruction.
+		line := function.Chunk.GetLine(frame.ip - 1)
+		if i == vm.frameCount-1 {
+			diagnostic.Line = line
+		}
+		trace := loxerrors.Frame{Function: function.Name, Line: line}
+		diagnostic.Notes = append(diagnostic.Notes, trace.String())
+	}
+
+	vm.loxerror.Report(diagnostic)
+	vm.resetStack()
+}
+
+func (vm *VM) defineNative(name string, function NativeFn) {
+	vm.globals[name] = &ObjNative{Name: name, Function: function}
+}
+
+func (vm *VM) push(value Value) {
+	vm.stack[vm.stackTop] = value
+	vm.stackTop++
+}
+
+func (vm *VM) pop() Value {
+	vm.stackTop--
+	return vm.stack[vm.stackTop]
+}
+
+func (vm *VM) peek(distance int) Value {
+	return vm.stack[vm.stackTop-1-distance]
+}
+
+func (vm *VM) call(closure *ObjClosure, argCount int) bool {
+	if argCount != closure.Function.Arity {
+		vm.runtimeError("Expected %d arguments but got %d.", closure.Function.Arity, argCount)
+		return false
+	}
+
+	if vm.frameCount == FRAMES_MAX {
+		vm.runtimeError("Stack overflow.")
+		return false
+	}
+
+	frame := &vm.frames[vm.frameCount]
+	vm.frameCount++
+	frame.closure = closure
+	frame.ip = 0
+	frame.slots = vm.stackTop - argCount - 1
+	return true
+}
+
+func (vm *VM) callValue(callee Value, argCount int) bool {
+	switch callee := callee.(type) {
+	case *ObjBoundMethod:
+		vm.stack[vm.stackTop-argCount-1] = callee.Receiver
+		return vm.call(callee.Method, argCount)
+	case *ObjClass:
+		vm.stack[vm.stackTop-argCount-1] = NewInstance(callee)
+		if initializer, ok := callee.Methods["init"]; ok {
+			return vm.call(initializer, argCount)
+		} else if argCount != 0 {
+			vm.runtimeError("Expected 0 arguments but got %d.", argCount)
+			return false
+		}
+		return true
+	case *ObjClosure:
+		return vm.call(callee, argCount)
+	case *ObjNative:
+		result := callee.Function(vm.stack[vm.stackTop-argCount : vm.stackTop])
+		vm.stackTop -= argCount + 1
+		vm.push(result)
+		return true
+	}
+
+	vm.runtimeError("Can only call functions and classes.")
+	return false
+}
+
+func (vm *VM) invokeFromClass(class *ObjClass, name string, argCount int) bool {
+	method, ok := class.Methods[name]
+	if !ok {
+		vm.runtimeError("Undefined property '%s'.", name)
+		return false
+	}
+	return vm.call(method, argCount)
+}
+
+func (vm *VM) invoke(name string, argCount int) bool {
+	receiver := vm.peek(argCount)
+
+	instance, ok := receiver.(*ObjInstance)
+	if !ok {
+		vm.runtimeError("Only instances have methods.")
+		return false
+	}
+
+	if value, ok := instance.Fields[name]; ok {
+		vm.stack[vm.stackTop-argCount-1] = value
+		return vm.callValue(value, argCount)
+	}
+
+	return vm.invokeFromClass(instance.Class, name, argCount)
+}
+
+func (vm *VM) bindMethod(class *ObjClass, name string) bool {
+	method, ok := class.Methods[name]
+	if !ok {
+		vm.runtimeError("Undefined property '%s'.", name)
+		return false
+	}
+
+	bound := &ObjBoundMethod{Receiver: vm.peek(0), Method: method}
+	vm.pop()
+	vm.push(bound)
+	return true
+}
+
+func (vm *VM) captureUpvalue(slot int) *ObjUpvalue {
+	var prevUpvalue *ObjUpvalue
+	upvalue := vm.openUpvalues
+	for upvalue != nil && upvalue.slot > slot {
+		prevUpvalue = upvalue
+		upvalue = upvalue.next
+	}
+
+	if upvalue != nil && upvalue.slot == slot {
+		return upvalue
+	}
+
+	createdUpvalue := &ObjUpvalue{location: &vm.stack[slot], slot: slot, next: upvalue}
+	if prevUpvalue == nil {
+		vm.openUpvalues = createdUpvalue
+	} else {
+		prevUpvalue.next = createdUpvalue
+	}
+	return createdUpvalue
+}
+
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= last {
+		upvalue := vm.openUpvalues
+		upvalue.closed = *upvalue.location
+		upvalue.location = &upvalue.closed
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+func (vm *VM) defineMethod(name string) {
+	method := vm.peek(0).(*ObjClosure)
+	class := vm.peek(1).(*ObjClass)
+	class.Methods[name] = method
+	vm.pop()
+}
+
+func (vm *VM) run() InterpretResult {
+	frame := &vm.frames[vm.frameCount-1]
+
+	readByte := func() byte {
+		b := frame.closure.Function.Chunk.Code[frame.ip]
+		frame.ip++
+		return b
+	}
+	readShort := func() int {
+		frame.ip += 2
+		code := frame.closure.Function.Chunk.Code
+		return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+	}
+	readConstant := func() Value {
+		return frame.closure.Function.Chunk.Constants[readByte()]
+	}
+	readString := func() string {
+		return readConstant().(string)
+	}
+
+	for {
+		instruction := Opcode(readByte())
+		switch instruction {
+		case OP_CONSTANT:
+			vm.push(readConstant())
+		case OP_NIL:
+			vm.push(nil)
+		case OP_TRUE:
+			vm.push(true)
+		case OP_FALSE:
+			vm.push(false)
+		case OP_POP:
+			vm.pop()
+		case OP_GET_LOCAL:
+			slot := readByte()
+			vm.push(vm.stack[frame.slots+int(slot)])
+		case OP_SET_LOCAL:
+			slot := readByte()
+			vm.stack[frame.slots+int(slot)] = vm.peek(0)
+		case OP_GET_GLOBAL:
+			name := readString()
+			value, ok := vm.globals[name]
+			if !ok {
+				vm.runtimeError("Undefined variable '%s'.", name)
+				return INTERPRET_RUNTIME_ERROR
+			}
+			vm.push(value)
+		case OP_DEFINE_GLOBAL:
+			name := readString()
+			vm.globals[name] = vm.peek(0)
+			vm.pop()
+		case OP_SET_GLOBAL:
+			name := readString()
+			if _, ok := vm.globals[name]; !ok {
+				vm.runtimeError("Undefined variable '%s'.", name)
+				return INTERPRET_RUNTIME_ERROR
+			}
+			vm.globals[name] = vm.peek(0)
+		case OP_GET_UPVALUE:
+			slot := readByte()
+			vm.push(*frame.closure.Upvalues[slot].location)
+		case OP_SET_UPVALUE:
+			slot := readByte()
+			*frame.closure.Upvalues[slot].location = vm.peek(0)
+		case OP_GET_PROPERTY:
+			instance, ok := vm.peek(0).(*ObjInstance)
+			if !ok {
+				vm.runtimeError("Only instances have properties.")
+				return INTERPRET_RUNTIME_ERROR
+			}
+
+			name := readString()
+			if value, ok := instance.Fields[name]; ok {
+				vm.pop() // Instance.
+				vm.push(value)
+				break
+			}
+
+			if !vm.bindMethod(instance.Class, name) {
+				return INTERPRET_RUNTIME_ERROR
+			}
+		case OP_SET_PROPERTY:
+			instance, ok := vm.peek(1).(*ObjInstance)
+			if !ok {
+				vm.runtimeError("Only instances have fields.")
+				return INTERPRET_RUNTIME_ERROR
+			}
+
+			instance.Fields[readString()] = vm.peek(0)
+			value := vm.pop()
+			vm.pop()
+			vm.push(value)
+		case OP_EQUAL:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(valuesEqual(a, b))
+		case OP_GREATER, OP_LESS, OP_SUBTRACT, OP_MULTIPLY, OP_DIVIDE:
+			b, ok1 := vm.peek(0).(float64)
+			a, ok2 := vm.peek(1).(float64)
+			if !ok1 || !ok2 {
+				vm.runtimeError("Operands must be numbers.")
+				return INTERPRET_RUNTIME_ERROR
+			}
+			vm.stackTop -= 2
+			switch instruction {
+			case OP_GREATER:
+				vm.push(a > b)
+			case OP_LESS:
+				vm.push(a < b)
+			case OP_SUBTRACT:
+				vm.push(a - b)
+			case OP_MULTIPLY:
+				vm.push(a * b)
+			case OP_DIVIDE:
+				vm.push(a / b)
+			}
+		case OP_ADD:
+			switch b := vm.peek(0).(type) {
+			case string:
+				a, ok := vm.peek(1).(string)
+				if !ok {
+					vm.runtimeError("Operands must be two numbers or two strings.")
+					return INTERPRET_RUNTIME_ERROR
+				}
+				vm.stackTop -= 2
+				vm.push(a + b)
+			case float64:
+				a, ok := vm.peek(1).(float64)
+				if !ok {
+					vm.runtimeError("Operands must be two numbers or two strings.")
+					return INTERPRET_RUNTIME_ERROR
+				}
+				vm.stackTop -= 2
+				vm.push(a + b)
+			default:
+				vm.runtimeError("Operands must be two numbers or two strings.")
+				return INTERPRET_RUNTIME_ERROR
+			}
+		case OP_NOT:
+			vm.push(isFalsey(vm.pop()))
+		case OP_NEGATE:
+			value, ok := vm.peek(0).(float64)
+			if !ok {
+				vm.runtimeError("Operand must be a number.")
+				return INTERPRET_RUNTIME_ERROR
+			}
+			vm.pop()
+			vm.push(-value)
+		case OP_PRINT:
+			fmt.Fprintln(vm.stdout, Stringify(vm.pop()))
+		case OP_JUMP:
+			offset := readShort()
+			frame.ip += offset
+		case OP_JUMP_IF_FALSE:
+			offset := readShort()
+			if isFalsey(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case OP_LOOP:
+			offset := readShort()
+			frame.ip -= offset
+		case OP_CALL:
+			argCount := int(readByte())
+			if !vm.callValue(vm.peek(argCount), argCount) {
+				return INTERPRET_RUNTIME_ERROR
+			}
+			frame = &vm.frames[vm.frameCount-1]
+		case OP_INVOKE:
+			method := readString()
+			argCount := int(readByte())
+			if !vm.invoke(method, argCount) {
+				return INTERPRET_RUNTIME_ERROR
+			}
+			frame = &vm.frames[vm.frameCount-1]
+		case OP_CLOSURE:
+			function := readConstant().(*ObjFunction)
+			closure := NewClosure(function)
+			vm.push(closure)
+			for i := range closure.Upvalues {
+				isLocal := readByte()
+				index := int(readByte())
+				if isLocal == 1 {
+					closure.Upvalues[i] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.Upvalues[i] = frame.closure.Upvalues[index]
+				}
+			}
+		case OP_CLOSE_UPVALUE:
+			vm.closeUpvalues(vm.stackTop - 1)
+			vm.pop()
+		case OP_RETURN:
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			vm.frameCount--
+			if vm.frameCount == 0 {
+				vm.pop()
+				return INTERPRET_OK
+			}
+
+			vm.stackTop = frame.slots
+			vm.push(result)
+			frame = &vm.frames[vm.frameCount-1]
+		case OP_CLASS:
+			vm.push(NewClass(readString()))
+		case OP_METHOD:
+			vm.defineMethod(readString())
+		}
+	}
+}
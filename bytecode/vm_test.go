@@ -0,0 +1,126 @@
+package bytecode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestInterpret(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   Value
+	}{
+		{"arithmetic", "var result = -(1 + 2) * 3 - 4 / 2;", -11.0},
+		{"strings", `var result = "foo" + "bar";`, "foobar"},
+		{"comparison", "var result = !(1 >= 2) and 3 <= 3 and 1 != 2;", true},
+		{"logical", "var result = nil or false or 7;", 7.0},
+		{"locals", "var result; { var a = 1; { var b = a + 1; result = b; } }", 2.0},
+		{"while", "var result = 0; var i = 0; while (i < 5) { result = result + i; i = i + 1; }", 10.0},
+		{"for", "var result = 1; for (var i = 0; i < 10; i = i + 1) result = result * 2;", 1024.0},
+		{"if", "var result; if (1 > 2) result = 1; else result = 2;", 2.0},
+		{"recursion", `
+			fun fib(n) { if (n < 2) return n; return fib(n - 2) + fib(n - 1); }
+			var result = fib(15);`, 610.0},
+		{"closure", `
+			fun makeCounter() {
+				var i = 0;
+				fun count() { i = i + 1; return i; }
+				return count;
+			}
+			var counter = makeCounter();
+			counter();
+			var result = counter();`, 2.0},
+		{"closed upvalue", `
+			var get;
+			var set;
+			{
+				var a = 1;
+				fun g() { return a; }
+				fun s(v) { a = v; }
+				get = g;
+				set = s;
+			}
+			set(5);
+			var result = get();`, 5.0},
+		{"lambda", "var result = (fun (a, b) { return a - b; })(5, 3);", 2.0},
+		{"class", `
+			class Point {
+				init(x, y) { this.x = x; this.y = y; }
+				sum() { return this.x + this.y; }
+			}
+			var p = Point(1, 2);
+			p.x = 10;
+			var result = p.sum();`, 12.0},
+		{"bound method", `
+			class Box {
+				init(v) { this.v = v; }
+				get() { return this.v; }
+			}
+			var get = Box(3).get;
+			var result = get();`, 3.0},
+		{"field call", `
+			class Holder {}
+			fun double(n) { return n * 2; }
+			var h = Holder();
+			h.f = double;
+			var result = h.f(4);`, 8.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := New()
+			if result := vm.Interpret(tt.source); result != INTERPRET_OK {
+				t.Fatalf("Interpret returned %d", result)
+			}
+			if got := vm.globals["result"]; got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpretErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   InterpretResult
+	}{
+		{"print 1 +;", INTERPRET_COMPILE_ERROR},
+		{"return 1;", INTERPRET_COMPILE_ERROR},
+		{"{ var a = a; }", INTERPRET_COMPILE_ERROR},
+		{"print this;", INTERPRET_COMPILE_ERROR},
+		{`print "cost: ${5}";`, INTERPRET_COMPILE_ERROR},
+		{`print 1 + "a";`, INTERPRET_RUNTIME_ERROR},
+		{"print undefined;", INTERPRET_RUNTIME_ERROR},
+		{"fun f(a) {} f();", INTERPRET_RUNTIME_ERROR},
+		{"fun f() { f(); } f();", INTERPRET_RUNTIME_ERROR},
+		{"class A {} A().missing;", INTERPRET_RUNTIME_ERROR},
+	}
+
+	for _, tt := range tests {
+		if got := New().Interpret(tt.source); got != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	vm := New()
+	vm.SetOutput(&stdout, &stderr)
+
+	vm.Interpret("print 1 + 2;\nprint nil + 1;")
+	if got := stdout.String(); got != "3\n" {
+		t.Errorf("stdout = %q, want %q", got, "3\n")
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "Operands must be two numbers or two strings.\n") {
+		t.Errorf("stderr = %q", got)
+	}
+
+	var out bytes.Buffer
+	vm.RunPrompt(strings.NewReader("var a = 4;\nprint a;\n"), &out)
+	if got, want := out.String(), PROMPT+PROMPT+"4\n"+PROMPT+"\n"; got != want {
+		t.Errorf("prompt output = %q, want %q", got, want)
+	}
+}
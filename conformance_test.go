@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"lox/engine"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The conformance corpus lives in testdata/. Each .lox file states what it
+// should do with inline comments, following the Crafting Interpreters suite:
+//
+//	print 1; // expect: 1
+//	print;   // Error at ';': Expect expression.
+//	// [line 3] Error: Unterminated string.
+//	-"s";    // expect runtime error: Operand must be a number.
+var (
+	expectedOutputPattern       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectedErrorPattern        = regexp.MustCompile(`// (Error.*)`)
+	expectedErrorLinePattern    = regexp.MustCompile(`// \[line (\d+)\] (Error.*)`)
+	expectedRuntimeErrorPattern = regexp.MustCompile(`// expect runtime error: (.+)`)
+	syntaxErrorPattern          = regexp.MustCompile(`^\[line (\d+)\] (Error.+)$`)
+	stackTracePattern           = regexp.MustCompile(`^\[line (\d+)\]`)
+	snippetPattern              = regexp.MustCompile(`^ *\d* \| `)
+)
+
+// skip lists corpus directories an engine does not implement yet. A
+// directory named after an engine holds scripts only that engine runs.
+var skip = map[string][]string{
+	"treewalk": {"bytecode/"},
+	"bytecode": {"exceptions/", "break/in_finally.lox", "list/", "map/", "interpolation/", "string_natives/", "math/"},
+}
+
+// Result is what running one script produced.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Runner executes the Lox script at path. Any execution backend can be
+// checked against the corpus by supplying a Runner for it.
+type Runner func(path string) (Result, error)
+
+const mainEnv = "LOX_CONFORMANCE_MAIN"
+
+func TestMain(m *testing.M) {
+	// CommandRunner re-executes the test binary, which then acts as lox.
+	if os.Getenv(mainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// CommandRunner runs scripts with the lox command using the named engine.
+func CommandRunner(engineName string) Runner {
+	return func(path string) (Result, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(os.Args[0], "--engine="+engineName, path)
+		cmd.Env = append(os.Environ(), mainEnv+"=1")
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return Result{}, err
+		}
+		return Result{stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()}, nil
+	}
+}
+
+func TestConformance(t *testing.T) {
+	for _, name := range engine.Names() {
+		t.Run(name, func(t *testing.T) {
+			RunCorpus(t, "testdata", CommandRunner(name), skip[name])
+		})
+	}
+}
+
+// RunCorpus checks every script under dir with runner, one subtest per file.
+func RunCorpus(t *testing.T, dir string, runner Runner, skipped []string) {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".lox" {
+			return err
+		}
+
+		name, _ := filepath.Rel(dir, path)
+		name = filepath.ToSlash(name)
+		t.Run(name, func(t *testing.T) {
+			for _, prefix := range skipped {
+				if strings.HasPrefix(name, prefix) {
+					t.Skipf("not supported by this engine")
+				}
+			}
+			t.Parallel()
+
+			expected, err := parseExpectations(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := runner(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, failure := range expected.check(result) {
+				t.Error(failure)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type expectations struct {
+	output           []string
+	compileErrors    map[string]bool
+	runtimeError     string
+	runtimeErrorLine int
+	exitCode         int
+}
+
+func parseExpectations(path string) (*expectations, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	e := &expectations{compileErrors: make(map[string]bool)}
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+
+		if match := expectedOutputPattern.FindStringSubmatch(line); match != nil {
+			e.output = append(e.output, match[1])
+		} else if match := expectedRuntimeErrorPattern.FindStringSubmatch(line); match != nil {
+			e.runtimeError = match[1]
+			e.runtimeErrorLine = lineNum
+			e.exitCode = engine.EXIT_SOFTWARE
+		} else if match := expectedErrorLinePattern.FindStringSubmatch(line); match != nil {
+			e.compileErrors[fmt.Sprintf("[line %s] %s", match[1], match[2])] = true
+			e.exitCode = engine.EXIT_DATA_ERROR
+		} else if match := expectedErrorPattern.FindStringSubmatch(line); match != nil {
+			e.compileErrors[fmt.Sprintf("[line %d] %s", lineNum, match[1])] = true
+			e.exitCode = engine.EXIT_DATA_ERROR
+		}
+	}
+	return e, scanner.Err()
+}
+
+func (e *expectations) check(result Result) []string {
+	var failures []string
+
+	// Source snippets under diagnostics are for people, not the corpus.
+	var stderr []string
+	for _, line := range splitLines(result.Stderr) {
+		if !snippetPattern.MatchString(line) {
+			stderr = append(stderr, line)
+		}
+	}
+	if e.runtimeError != "" {
+		failures = append(failures, e.checkRuntimeError(stderr)...)
+	} else {
+		failures = append(failures, e.checkCompileErrors(stderr)...)
+	}
+
+	if result.ExitCode != e.exitCode {
+		failures = append(failures, fmt.Sprintf("expected exit code %d, got %d", e.exitCode, result.ExitCode))
+	}
+
+	if output := splitLines(result.Stdout); !equalLines(output, e.output) {
+		failures = append(failures, "stdout differs:\n"+diffLines(e.output, output))
+	}
+	return failures
+}
+
+func (e *expectations) checkRuntimeError(stderr []string) []string {
+	if len(stderr) < 2 {
+		return []string{fmt.Sprintf("expected runtime error %q and a stack trace, got:\n%s", e.runtimeError, strings.Join(stderr, "\n"))}
+	}
+
+	var failures []string
+	if stderr[0] != e.runtimeError {
+		failures = append(failures, fmt.Sprintf("expected runtime error %q, got %q", e.runtimeError, stderr[0]))
+	}
+
+	match := stackTracePattern.FindStringSubmatch(stderr[1])
+	if match == nil {
+		failures = append(failures, fmt.Sprintf("expected stack trace, got %q", stderr[1]))
+	} else if line, _ := strconv.Atoi(match[1]); line != e.runtimeErrorLine {
+		failures = append(failures, fmt.Sprintf("expected runtime error on line %d, got line %d", e.runtimeErrorLine, line))
+	}
+	return failures
+}
+
+func (e *expectations) checkCompileErrors(stderr []string) []string {
+	var failures []string
+	found := make(map[string]bool)
+
+	for _, line := range stderr {
+		if syntaxErrorPattern.MatchString(line) && e.compileErrors[line] {
+			found[line] = true
+		} else {
+			failures = append(failures, fmt.Sprintf("unexpected output on stderr: %q", line))
+		}
+	}
+
+	for message := range e.compileErrors {
+		if !found[message] {
+			failures = append(failures, fmt.Sprintf("missing expected error: %q", message))
+		}
+	}
+	return failures
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffLines renders a line diff of want against got, marking missing lines
+// with '-' and unexpected ones with '+'.
+func diffLines(want, got []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of want[i:]
+	// and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			fmt.Fprintf(&b, "  %s\n", want[i])
+			i++
+			j++
+		case i < len(want) && (j == len(got) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&b, "- %s\n", want[i])
+			i++
+		default:
+			fmt.Fprintf(&b, "+ %s\n", got[j])
+			j++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"io"
+	"sort"
+	"sync"
+)
+
+// Exit codes follow the sysexits conventions used by the book.
+const (
+	EXIT_OK         = 0
+	EXIT_USAGE      = 64
+	EXIT_DATA_ERROR = 65
+	EXIT_SOFTWARE   = 70
+	EXIT_IO_ERROR   = 74
+)
+
+const DEFAULT_ENGINE = "treewalk"
+
+// Engine is an execution backend for Lox programs. RunSource and RunFile
+// return the process exit code instead of exiting themselves. Program
+// output and error reports go to the writers given to SetOutput, which
+// default to os.Stdout and os.Stderr; RunPrompt writes program output to
+// out instead.
+type Engine interface {
+	RunSource(source string) int
+	RunFile(filename string) int
+	RunPrompt(in io.Reader, out io.Writer)
+	SetOutput(stdout, stderr io.Writer)
+}
+
+type Factory func() Engine
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes an engine available under name. It panics if name is
+// already taken, since that is always a programming error.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic("engine: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// New creates a fresh instance of the named engine.
+func New(name string) (Engine, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	factory, ok := factories[name]
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
+
+// Names lists the registered engines in sorted order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
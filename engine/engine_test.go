@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"io"
+	"testing"
+)
+
+type stubEngine struct{}
+
+func (stubEngine) RunSource(source string) int           { return EXIT_OK }
+func (stubEngine) RunFile(filename string) int           { return EXIT_OK }
+func (stubEngine) RunPrompt(in io.Reader, out io.Writer) {}
+func (stubEngine) SetOutput(stdout, stderr io.Writer)    {}
+
+func TestRegistry(t *testing.T) {
+	Register("stub", func() Engine { return stubEngine{} })
+
+	if _, ok := New("stub"); !ok {
+		t.Fatal("registered engine not found")
+	}
+	if _, ok := New("missing"); ok {
+		t.Fatal("unregistered engine found")
+	}
+
+	found := false
+	for _, name := range Names() {
+		found = found || name == "stub"
+	}
+	if !found {
+		t.Errorf("Names() = %v, missing stub", Names())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate Register did not panic")
+		}
+	}()
+	Register("stub", func() Engine { return stubEngine{} })
+}
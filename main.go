@@ -1,20 +1,60 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	lox "lox/treewalk"
+	"lox/bytecode"
+	"lox/engine"
 	"os"
+	"strings"
+
+	_ "lox/treewalk"
+)
+
+var (
+	disasm     = flag.Bool("disasm", false, "print the compiled bytecode of the script instead of running it")
+	engineName = flag.String("engine", engine.DEFAULT_ENGINE, "execution backend: "+strings.Join(engine.Names(), ", "))
 )
 
 func main() {
-	l := lox.New()
-
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: lox [script]")
-		os.Exit(64)
-	} else if len(os.Args) == 2 {
-		l.RunFile(os.Args[1])
-	} else {
-		l.RunPrompt(os.Stdin, os.Stdout)
+	flag.Usage = func() {
+		fmt.Println("Usage: lox [--engine=name] [--disasm] [script]")
+		fmt.Println("Engines: " + strings.Join(engine.Names(), ", "))
+	}
+	flag.Parse()
+
+	if flag.NArg() > 1 || (*disasm && flag.NArg() != 1) {
+		flag.Usage()
+		os.Exit(engine.EXIT_USAGE)
+	}
+
+	if *disasm {
+		os.Exit(disassemble(flag.Arg(0)))
+	}
+
+	e, ok := engine.New(*engineName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown engine %q. Available engines: %s\n", *engineName, strings.Join(engine.Names(), ", "))
+		os.Exit(engine.EXIT_USAGE)
+	}
+
+	if flag.NArg() == 1 {
+		os.Exit(e.RunFile(flag.Arg(0)))
+	}
+	e.RunPrompt(os.Stdin, os.Stdout)
+}
+
+func disassemble(filename string) int {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %q: %v\n", filename, err)
+		return engine.EXIT_IO_ERROR
+	}
+
+	function := bytecode.CompileSource(string(data))
+	if function == nil {
+		return engine.EXIT_DATA_ERROR
 	}
+	fmt.Print(bytecode.Disassemble(function.Chunk, "script"))
+	return engine.EXIT_OK
 }
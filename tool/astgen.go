@@ -15,8 +15,17 @@ var exprAnnotations = []string{
 	"Logical : Left Expr, Operator token.Token, Right Expr",
 	"Binary : Left Expr, Operator token.Token, Right Expr",
 	"Call : Callee Expr, Paren token.Token, Arguments []Expr",
+	"Get : Object Expr, Name token.Token",
+	"Lambda : Keyword token.Token, Params []token.Token, Body []Stmt",
+	"Set : Object Expr, Name token.Token, Value Expr",
+	"This : Keyword token.Token",
 	"Variable : Name token.Token",
 	"Assign : Name token.Token, Value Expr",
+	"List : Bracket token.Token, Elements []Expr",
+	"Map : Brace token.Token, Keys []Expr, Values []Expr",
+	"Interpolation : Parts []Expr",
+	"Index : Object Expr, Bracket token.Token, Index Expr",
+	"SetIndex : Object Expr, Bracket token.Token, Index Expr, Value Expr",
 }
 
 var stmtAnnotations = []string{
@@ -24,10 +33,15 @@ var stmtAnnotations = []string{
 	"Return : Keyword token.Token, Value Expr",
 	"Var : Name token.Token , Initializer Expr",
 	"Block : Statements []Stmt",
+	"Class : Name token.Token, Methods []*Function",
 	"Expression : Expression Expr",
 	"Function : Name token.Token, Params []token.Token, Body []Stmt",
 	"If : Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
-	"While : Condition Expr, Body Stmt",
+	"While : Condition Expr, Body Stmt, Increment Expr",
+	"Throw : Keyword token.Token, Value Expr",
+	"Try : Body *Block, CatchName token.Token, CatchBody *Block, FinallyBody *Block",
+	"Break : Keyword token.Token",
+	"Continue : Keyword token.Token",
 }
 
 func main() {
@@ -47,13 +61,14 @@ func main() {
 
 func defineAst(out, base string, types []string) {
 	source := "package ast\n"
-	source += `import "glox/treewalk/token"`
+	source += `import "lox/treewalk/token"`
 	source += fmt.Sprintln()
 
 	source += defineVisitor(base, types)
 
 	source += fmt.Sprintf(`
 type %s interface {
+	Node
 	Accept(v %sVisitor) any
 }
     `, base, base)
@@ -87,6 +102,7 @@ func defineType(base, name, fields string) string {
 	var source string
 
 	source += fmt.Sprintf("type %s struct {\n", name)
+	source += "node\n"
 
 	// fields
 	flds := strings.Split(fields, ",")
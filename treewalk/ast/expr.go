@@ -9,15 +9,26 @@ type ExprVisitor interface {
 	VisitLogicalExpr(expr *Logical) any
 	VisitBinaryExpr(expr *Binary) any
 	VisitCallExpr(expr *Call) any
+	VisitGetExpr(expr *Get) any
+	VisitLambdaExpr(expr *Lambda) any
+	VisitSetExpr(expr *Set) any
+	VisitThisExpr(expr *This) any
 	VisitVariableExpr(expr *Variable) any
 	VisitAssignExpr(expr *Assign) any
+	VisitListExpr(expr *List) any
+	VisitMapExpr(expr *Map) any
+	VisitInterpolationExpr(expr *Interpolation) any
+	VisitIndexExpr(expr *Index) any
+	VisitSetIndexExpr(expr *SetIndex) any
 }
 
 type Expr interface {
+	Node
 	Accept(v ExprVisitor) any
 }
 
 type Literal struct {
+	node
 	Value any
 }
 
@@ -30,6 +41,7 @@ func (e *Literal) Accept(v ExprVisitor) any {
 }
 
 type Grouping struct {
+	node
 	Expression Expr
 }
 
@@ -42,6 +54,7 @@ func (e *Grouping) Accept(v ExprVisitor) any {
 }
 
 type Unary struct {
+	node
 	Operator token.Token
 	Right    Expr
 }
@@ -55,6 +68,7 @@ func (e *Unary) Accept(v ExprVisitor) any {
 }
 
 type Logical struct {
+	node
 	Left     Expr
 	Operator token.Token
 	Right    Expr
@@ -69,6 +83,7 @@ func (e *Logical) Accept(v ExprVisitor) any {
 }
 
 type Binary struct {
+	node
 	Left     Expr
 	Operator token.Token
 	Right    Expr
@@ -83,6 +98,7 @@ func (e *Binary) Accept(v ExprVisitor) any {
 }
 
 type Call struct {
+	node
 	Callee    Expr
 	Paren     token.Token
 	Arguments []Expr
@@ -96,7 +112,65 @@ func (e *Call) Accept(v ExprVisitor) any {
 	return v.VisitCallExpr(e)
 }
 
+type Get struct {
+	node
+	Object Expr
+	Name   token.Token
+}
+
+func NewGet(object Expr, name token.Token) Expr {
+	return &Get{Object: object, Name: name}
+}
+
+func (e *Get) Accept(v ExprVisitor) any {
+	return v.VisitGetExpr(e)
+}
+
+type Lambda struct {
+	node
+	Keyword token.Token
+	Params  []token.Token
+	Body    []Stmt
+}
+
+func NewLambda(keyword token.Token, params []token.Token, body []Stmt) Expr {
+	return &Lambda{Keyword: keyword, Params: params, Body: body}
+}
+
+func (e *Lambda) Accept(v ExprVisitor) any {
+	return v.VisitLambdaExpr(e)
+}
+
+type Set struct {
+	node
+	Object Expr
+	Name   token.Token
+	Value  Expr
+}
+
+func NewSet(object Expr, name token.Token, value Expr) Expr {
+	return &Set{Object: object, Name: name, Value: value}
+}
+
+func (e *Set) Accept(v ExprVisitor) any {
+	return v.VisitSetExpr(e)
+}
+
+type This struct {
+	node
+	Keyword token.Token
+}
+
+func NewThis(keyword token.Token) Expr {
+	return &This{Keyword: keyword}
+}
+
+func (e *This) Accept(v ExprVisitor) any {
+	return v.VisitThisExpr(e)
+}
+
 type Variable struct {
+	node
 	Name token.Token
 }
 
@@ -109,6 +183,7 @@ func (e *Variable) Accept(v ExprVisitor) any {
 }
 
 type Assign struct {
+	node
 	Name  token.Token
 	Value Expr
 }
@@ -120,3 +195,76 @@ func NewAssign(name token.Token, value Expr) Expr {
 func (e *Assign) Accept(v ExprVisitor) any {
 	return v.VisitAssignExpr(e)
 }
+
+type List struct {
+	node
+	Bracket  token.Token
+	Elements []Expr
+}
+
+func NewList(bracket token.Token, elements []Expr) Expr {
+	return &List{Bracket: bracket, Elements: elements}
+}
+
+func (e *List) Accept(v ExprVisitor) any {
+	return v.VisitListExpr(e)
+}
+
+type Map struct {
+	node
+	Brace  token.Token
+	Keys   []Expr
+	Values []Expr
+}
+
+func NewMap(brace token.Token, keys []Expr, values []Expr) Expr {
+	return &Map{Brace: brace, Keys: keys, Values: values}
+}
+
+func (e *Map) Accept(v ExprVisitor) any {
+	return v.VisitMapExpr(e)
+}
+
+type Interpolation struct {
+	node
+	Parts []Expr
+}
+
+func NewInterpolation(parts []Expr) Expr {
+	return &Interpolation{Parts: parts}
+}
+
+func (e *Interpolation) Accept(v ExprVisitor) any {
+	return v.VisitInterpolationExpr(e)
+}
+
+type Index struct {
+	node
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+}
+
+func NewIndex(object Expr, bracket token.Token, index Expr) Expr {
+	return &Index{Object: object, Bracket: bracket, Index: index}
+}
+
+func (e *Index) Accept(v ExprVisitor) any {
+	return v.VisitIndexExpr(e)
+}
+
+type SetIndex struct {
+	node
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+	Value   Expr
+}
+
+func NewSetIndex(object Expr, bracket token.Token, index Expr, value Expr) Expr {
+	return &SetIndex{Object: object, Bracket: bracket, Index: index, Value: value}
+}
+
+func (e *SetIndex) Accept(v ExprVisitor) any {
+	return v.VisitSetIndexExpr(e)
+}
@@ -0,0 +1,23 @@
+package ast
+
+import "lox/treewalk/token"
+
+// Node is implemented by every expression and statement. The parser sets
+// the span to the full source range the node was parsed from.
+type Node interface {
+	Span() token.Span
+	SetSpan(span token.Span)
+}
+
+// node is embedded in each generated AST type.
+type node struct {
+	span token.Span
+}
+
+func (n *node) Span() token.Span {
+	return n.span
+}
+
+func (n *node) SetSpan(span token.Span) {
+	n.span = span
+}
@@ -7,17 +7,24 @@ type StmtVisitor interface {
 	VisitReturnStmt(expr *Return) any
 	VisitVarStmt(expr *Var) any
 	VisitBlockStmt(expr *Block) any
+	VisitClassStmt(expr *Class) any
 	VisitExpressionStmt(expr *Expression) any
 	VisitFunctionStmt(expr *Function) any
 	VisitIfStmt(expr *If) any
 	VisitWhileStmt(expr *While) any
+	VisitThrowStmt(expr *Throw) any
+	VisitTryStmt(expr *Try) any
+	VisitBreakStmt(expr *Break) any
+	VisitContinueStmt(expr *Continue) any
 }
 
 type Stmt interface {
+	Node
 	Accept(v StmtVisitor) any
 }
 
 type Print struct {
+	node
 	Expression Expr
 }
 
@@ -30,6 +37,7 @@ func (e *Print) Accept(v StmtVisitor) any {
 }
 
 type Return struct {
+	node
 	Keyword token.Token
 	Value   Expr
 }
@@ -43,6 +51,7 @@ func (e *Return) Accept(v StmtVisitor) any {
 }
 
 type Var struct {
+	node
 	Name        token.Token
 	Initializer Expr
 }
@@ -56,6 +65,7 @@ func (e *Var) Accept(v StmtVisitor) any {
 }
 
 type Block struct {
+	node
 	Statements []Stmt
 }
 
@@ -67,7 +77,22 @@ func (e *Block) Accept(v StmtVisitor) any {
 	return v.VisitBlockStmt(e)
 }
 
+type Class struct {
+	node
+	Name    token.Token
+	Methods []*Function
+}
+
+func NewClass(name token.Token, methods []*Function) Stmt {
+	return &Class{Name: name, Methods: methods}
+}
+
+func (e *Class) Accept(v StmtVisitor) any {
+	return v.VisitClassStmt(e)
+}
+
 type Expression struct {
+	node
 	Expression Expr
 }
 
@@ -80,6 +105,7 @@ func (e *Expression) Accept(v StmtVisitor) any {
 }
 
 type Function struct {
+	node
 	Name   token.Token
 	Params []token.Token
 	Body   []Stmt
@@ -94,6 +120,7 @@ func (e *Function) Accept(v StmtVisitor) any {
 }
 
 type If struct {
+	node
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
@@ -108,14 +135,72 @@ func (e *If) Accept(v StmtVisitor) any {
 }
 
 type While struct {
+	node
 	Condition Expr
 	Body      Stmt
+	Increment Expr
 }
 
-func NewWhile(condition Expr, body Stmt) Stmt {
-	return &While{Condition: condition, Body: body}
+func NewWhile(condition Expr, body Stmt, increment Expr) Stmt {
+	return &While{Condition: condition, Body: body, Increment: increment}
 }
 
 func (e *While) Accept(v StmtVisitor) any {
 	return v.VisitWhileStmt(e)
 }
+
+type Throw struct {
+	node
+	Keyword token.Token
+	Value   Expr
+}
+
+func NewThrow(keyword token.Token, value Expr) Stmt {
+	return &Throw{Keyword: keyword, Value: value}
+}
+
+func (e *Throw) Accept(v StmtVisitor) any {
+	return v.VisitThrowStmt(e)
+}
+
+type Try struct {
+	node
+	Body        *Block
+	CatchName   token.Token
+	CatchBody   *Block
+	FinallyBody *Block
+}
+
+func NewTry(body *Block, catchname token.Token, catchbody *Block, finallybody *Block) Stmt {
+	return &Try{Body: body, CatchName: catchname, CatchBody: catchbody, FinallyBody: finallybody}
+}
+
+func (e *Try) Accept(v StmtVisitor) any {
+	return v.VisitTryStmt(e)
+}
+
+type Break struct {
+	node
+	Keyword token.Token
+}
+
+func NewBreak(keyword token.Token) Stmt {
+	return &Break{Keyword: keyword}
+}
+
+func (e *Break) Accept(v StmtVisitor) any {
+	return v.VisitBreakStmt(e)
+}
+
+type Continue struct {
+	node
+	Keyword token.Token
+}
+
+func NewContinue(keyword token.Token) Stmt {
+	return &Continue{Keyword: keyword}
+}
+
+func (e *Continue) Accept(v StmtVisitor) any {
+	return v.VisitContinueStmt(e)
+}
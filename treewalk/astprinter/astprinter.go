@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"lox/treewalk/ast"
 	"lox/treewalk/token"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -18,10 +20,32 @@ func Stringify(o any) string {
 	if o == nil {
 		return "nil"
 	}
+	if n, ok := o.(float64); ok {
+		return FormatNumber(n)
+	}
 
 	return fmt.Sprint(o)
 }
 
+// FormatNumber formats a Lox number the way print shows it: as the shortest
+// decimal that reads back as n, with no fraction for integers ("3", "-0")
+// and an exponent only for very large or very small magnitudes.
+func FormatNumber(n float64) string {
+	switch {
+	case math.IsNaN(n):
+		return "NaN"
+	case math.IsInf(n, 1):
+		return "Infinity"
+	case math.IsInf(n, -1):
+		return "-Infinity"
+	}
+
+	if abs := math.Abs(n); abs == 0 || (abs >= 1e-6 && abs < 1e21) {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(n, 'e', -1, 64)
+}
+
 func (a ASTPrinter) PrintStmts(statements []ast.Stmt) string {
 	// fmt.Println(statements)
 	str := ""
@@ -59,6 +83,15 @@ func (a ASTPrinter) VisitBlockStmt(stmt *ast.Block) any {
 	return a.printBlockWithIdent(stmt, 0)
 }
 
+func (a ASTPrinter) VisitClassStmt(stmt *ast.Class) any {
+	str := "class " + stmt.Name.Lexeme + " {\n"
+	for _, method := range stmt.Methods {
+		str += strings.TrimPrefix(a.PrintStmt(method), "fun ") + "\n"
+	}
+	str += "}"
+	return str
+}
+
 func (a ASTPrinter) VisitVarStmt(stmt *ast.Var) any {
 	str := "var " + fmt.Sprintf("%v", stmt.Name.Lexeme)
 	if stmt.Initializer != nil {
@@ -73,15 +106,19 @@ func (a ASTPrinter) VisitExpressionStmt(stmt *ast.Expression) any {
 }
 
 func (a ASTPrinter) VisitFunctionStmt(stmt *ast.Function) any {
-	str := "fun " + stmt.Name.Lexeme + "("
-	lst := len(stmt.Params) - 1
+	return a.printFunction("fun "+stmt.Name.Lexeme, stmt.Params, stmt.Body)
+}
+
+func (a ASTPrinter) printFunction(head string, params []token.Token, body []ast.Stmt) string {
+	str := head + "("
+	lst := len(params) - 1
 	if lst >= 0 {
 		for i := 0; i < lst; i++ {
-			str += stmt.Params[i].Lexeme + ", "
+			str += params[i].Lexeme + ", "
 		}
-		str += stmt.Params[lst].Lexeme
+		str += params[lst].Lexeme
 	}
-	str += ") {\n" + a.PrintStmts(stmt.Body) + "\n}"
+	str += ") {\n" + a.PrintStmts(body) + "\n}"
 	return str
 }
 
@@ -90,15 +127,44 @@ func (a ASTPrinter) VisitPrintStmt(stmt *ast.Print) any {
 }
 
 func (a ASTPrinter) VisitReturnStmt(stmt *ast.Return) any {
+	if stmt.Value == nil {
+		return "return;"
+	}
 	return "return " + a.Print(stmt.Value) + ";"
 }
 
 func (a ASTPrinter) VisitWhileStmt(stmt *ast.While) any {
 	// fmt.Println(reflect.TypeOf(stmt.Condition))
 	// fmt.Println(reflect.TypeOf(stmt.Body.(*ast.Print).Expression.(*ast.Assign).Value))
+	if stmt.Increment != nil {
+		return "for (; " + a.Print(stmt.Condition) + "; " + a.Print(stmt.Increment) + ") " + a.PrintStmt(stmt.Body)
+	}
 	return "while (" + a.Print(stmt.Condition) + ") " + a.PrintStmt(stmt.Body)
 }
 
+func (a ASTPrinter) VisitBreakStmt(stmt *ast.Break) any {
+	return "break;"
+}
+
+func (a ASTPrinter) VisitContinueStmt(stmt *ast.Continue) any {
+	return "continue;"
+}
+
+func (a ASTPrinter) VisitThrowStmt(stmt *ast.Throw) any {
+	return "throw " + a.Print(stmt.Value) + ";"
+}
+
+func (a ASTPrinter) VisitTryStmt(stmt *ast.Try) any {
+	str := "try " + a.PrintStmt(stmt.Body)
+	if stmt.CatchBody != nil {
+		str += " catch (" + stmt.CatchName.Lexeme + ") " + a.PrintStmt(stmt.CatchBody)
+	}
+	if stmt.FinallyBody != nil {
+		str += " finally " + a.PrintStmt(stmt.FinallyBody)
+	}
+	return str
+}
+
 func (a ASTPrinter) VisitIfStmt(stmt *ast.If) any {
 	str := "if (" + a.Print(stmt.Condition) + ") " + a.PrintStmt(stmt.ThenBranch)
 	if stmt.ElseBranch != nil {
@@ -128,7 +194,7 @@ func (a ASTPrinter) VisitLiteralExpr(e *ast.Literal) any {
 		return fmt.Sprintf(`"%s"`, s)
 	}
 
-	return fmt.Sprintf("%v", e.Value)
+	return Stringify(e.Value)
 }
 
 func (a ASTPrinter) VisitLogicalExpr(e *ast.Logical) any {
@@ -168,6 +234,62 @@ func (a ASTPrinter) VisitCallExpr(e *ast.Call) any {
 	return str
 }
 
+func (a ASTPrinter) VisitGetExpr(e *ast.Get) any {
+	return a.Print(e.Object) + "." + e.Name.Lexeme
+}
+
+func (a ASTPrinter) VisitLambdaExpr(e *ast.Lambda) any {
+	return a.printFunction("fun ", e.Params, e.Body)
+}
+
+func (a ASTPrinter) VisitSetExpr(e *ast.Set) any {
+	return a.Print(e.Object) + "." + e.Name.Lexeme + " = " + a.Print(e.Value)
+}
+
+func (a ASTPrinter) VisitListExpr(e *ast.List) any {
+	elements := make([]string, len(e.Elements))
+	for i, element := range e.Elements {
+		elements[i] = a.Print(element)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (a ASTPrinter) VisitMapExpr(e *ast.Map) any {
+	entries := make([]string, len(e.Keys))
+	for i, key := range e.Keys {
+		entries[i] = a.Print(key) + ": " + a.Print(e.Values[i])
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// VisitInterpolationExpr prints the string as written, with its text parts
+// unescaped.
+func (a ASTPrinter) VisitInterpolationExpr(e *ast.Interpolation) any {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, part := range e.Parts {
+		if i%2 == 0 {
+			b.WriteString(part.(*ast.Literal).Value.(string))
+		} else {
+			b.WriteString("${" + a.Print(part) + "}")
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (a ASTPrinter) VisitIndexExpr(e *ast.Index) any {
+	return a.Print(e.Object) + "[" + a.Print(e.Index) + "]"
+}
+
+func (a ASTPrinter) VisitSetIndexExpr(e *ast.SetIndex) any {
+	return a.Print(e.Object) + "[" + a.Print(e.Index) + "] = " + a.Print(e.Value)
+}
+
+func (a ASTPrinter) VisitThisExpr(e *ast.This) any {
+	return "this"
+}
+
 func (a ASTPrinter) parenthesize(name string, exprs ...ast.Expr) string {
 	buf := bytes.Buffer{}
 
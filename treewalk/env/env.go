@@ -8,11 +8,10 @@ import (
 type Environment struct {
 	Values    map[string]any
 	enclosing *Environment
-	loxerror  *loxerrors.LoxErrors
 }
 
-func New(loxerror *loxerrors.LoxErrors, enclosing *Environment) *Environment {
-	env := Environment{loxerror: loxerror, enclosing: enclosing}
+func New(enclosing *Environment) *Environment {
+	env := Environment{enclosing: enclosing}
 	env.Values = make(map[string]any)
 	return &env
 }
@@ -26,7 +25,7 @@ func Copy(e1 *Environment) *Environment {
 	if e1.enclosing != nil {
 		enclosing = Copy(e1.enclosing)
 	}
-	return &Environment{values, enclosing, e1.loxerror}
+	return &Environment{values, enclosing}
 }
 
 func (env *Environment) Get(name token.Token) (any, error) {
@@ -38,9 +37,7 @@ func (env *Environment) Get(name token.Token) (any, error) {
 		return env.enclosing.Get(name)
 	}
 
-	err := loxerrors.NewErrorRuntime(name, "Undefined variable '"+name.Lexeme+"'.")
-	env.loxerror.RuntimeError(err)
-	return nil, err
+	return nil, loxerrors.NewErrorRuntime(name, "Undefined variable '"+name.Lexeme+"'.")
 }
 
 func (env *Environment) Define(name string, value any) {
@@ -54,11 +51,24 @@ func (env *Environment) Assign(name token.Token, value any) error {
 	}
 
 	if env.enclosing != nil {
-		env.enclosing.Assign(name, value)
-		return nil
+		return env.enclosing.Assign(name, value)
 	}
 
-	err := loxerrors.NewErrorRuntime(name, "Undefined variable '"+name.Lexeme+"'.")
-	env.loxerror.RuntimeError(err)
-	return err
+	return loxerrors.NewErrorRuntime(name, "Undefined variable '"+name.Lexeme+"'.")
+}
+
+func (env *Environment) GetAt(distance int, name string) any {
+	return env.ancestor(distance).Values[name]
+}
+
+func (env *Environment) AssignAt(distance int, name token.Token, value any) {
+	env.ancestor(distance).Values[name.Lexeme] = value
+}
+
+func (env *Environment) ancestor(distance int) *Environment {
+	environment := env
+	for i := 0; i < distance; i++ {
+		environment = environment.enclosing
+	}
+	return environment
 }
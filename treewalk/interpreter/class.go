@@ -0,0 +1,70 @@
+package interpreter
+
+import (
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/token"
+)
+
+type LoxClass struct {
+	name    string
+	methods map[string]*LoxFunction
+}
+
+func NewLoxClass(name string, methods map[string]*LoxFunction) *LoxClass {
+	return &LoxClass{name: name, methods: methods}
+}
+
+func (c *LoxClass) findMethod(name string) (*LoxFunction, bool) {
+	method, ok := c.methods[name]
+	return method, ok
+}
+
+func (c *LoxClass) call(interpreter *Interpreter, arguments []any) any {
+	instance := NewLoxInstance(c)
+	if initializer, ok := c.findMethod("init"); ok {
+		if err, ok := initializer.bind(instance).call(interpreter, arguments).(error); ok {
+			return err
+		}
+	}
+	return instance
+}
+
+func (c *LoxClass) arity() int {
+	if initializer, ok := c.findMethod("init"); ok {
+		return initializer.arity()
+	}
+	return 0
+}
+
+func (c *LoxClass) String() string {
+	return c.name
+}
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]any
+}
+
+func NewLoxInstance(class *LoxClass) *LoxInstance {
+	return &LoxInstance{class: class, fields: make(map[string]any)}
+}
+
+func (li *LoxInstance) Get(name token.Token) (any, *loxerrors.ErrorRuntime) {
+	if value, ok := li.fields[name.Lexeme]; ok {
+		return value, nil
+	}
+
+	if method, ok := li.class.findMethod(name.Lexeme); ok {
+		return method.bind(li), nil
+	}
+
+	return nil, loxerrors.NewErrorRuntime(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (li *LoxInstance) Set(name token.Token, value any) {
+	li.fields[name.Lexeme] = value
+}
+
+func (li *LoxInstance) String() string {
+	return li.class.name + " instance"
+}
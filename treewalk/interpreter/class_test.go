@@ -0,0 +1,105 @@
+package interpreter_test
+
+import (
+	"context"
+	"lox/treewalk/interpreter"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/parser"
+	"lox/treewalk/resolver"
+	"lox/treewalk/scanner"
+	"testing"
+)
+
+// runError interprets source, which must fail at run time, and returns the
+// runtime error.
+func runError(t *testing.T, source string) error {
+	t.Helper()
+	loxerror := loxerrors.NewWithSink(&loxerrors.Collector{})
+	tokens := scanner.New(source, loxerror).ScanTokens()
+	statements, err := parser.New(tokens, loxerror).Parse()
+	if err != nil || loxerror.HadError {
+		t.Fatalf("parse error in %q", source)
+	}
+
+	interp := interpreter.New(loxerror)
+	resolver.New(interp, loxerror).Resolve(statements)
+	if loxerror.HadError {
+		t.Fatalf("resolve error in %q", source)
+	}
+
+	if _, err := interp.Interpret(context.Background(), statements); err != nil {
+		return err
+	}
+	t.Fatalf("no runtime error in %q", source)
+	return nil
+}
+
+func TestClasses(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   any
+	}{
+		{"init returns this", `
+			class Point {
+				init(x) { this.x = x; }
+			}
+			var p = Point(1);
+			p.init(2) == p and p.x == 2;`, true},
+		{"early return in init", `
+			class Once {
+				init() {
+					this.state = "set";
+					return;
+					this.state = "unreachable";
+				}
+			}
+			var o = Once();
+			o.init() == o and o.state == "set";`, true},
+		{"bound method keeps this", `
+			class Greeter {
+				init(name) { this.name = name; }
+				greet() { return "hi " + this.name; }
+			}
+			var greet = Greeter("ann").greet;
+			var other = Greeter("bob");
+			other.greet = greet;
+			greet() + ", " + other.greet();`, "hi ann, hi ann"},
+		{"no init", `
+			class Empty {}
+			var e = Empty();
+			e.x = 1;
+			e.x;`, 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(t, tt.source); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{"property of number", `var n = 1; n.x;`, "Only instances have properties."},
+		{"property of class", `class A {} A.x;`, "Only instances have properties."},
+		{"field of string", `"s".x = 1;`, "Only instances have fields."},
+		{"undefined property", `class A {} A().missing;`, "Undefined property 'missing'."},
+		{"arguments without init", `class A {} A(1, 2);`, "Expected 0 arguments but got 2."},
+		{"wrong init arity", `class A { init(a) {} } A();`, "Expected 1 arguments but got 0."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := runError(t, tt.source); err.Error() != tt.message {
+				t.Errorf("got error %q, want %q", err, tt.message)
+			}
+		})
+	}
+}
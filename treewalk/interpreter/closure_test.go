@@ -0,0 +1,120 @@
+package interpreter_test
+
+import (
+	"context"
+	"lox/treewalk/interpreter"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/parser"
+	"lox/treewalk/resolver"
+	"lox/treewalk/scanner"
+	"testing"
+)
+
+// run interprets source and returns the value of its last expression statement.
+func run(t *testing.T, source string) any {
+	t.Helper()
+	loxerror := loxerrors.New()
+	tokens := scanner.New(source, loxerror).ScanTokens()
+	statements, err := parser.New(tokens, loxerror).Parse()
+	if err != nil || loxerror.HadError {
+		t.Fatalf("parse error in %q", source)
+	}
+
+	interp := interpreter.New(loxerror)
+	resolver.New(interp, loxerror).Resolve(statements)
+	if loxerror.HadError {
+		t.Fatalf("resolve error in %q", source)
+	}
+
+	value, err := interp.Interpret(context.Background(), statements)
+	if err != nil {
+		t.Fatalf("runtime error in %q: %v", source, err)
+	}
+	return value
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   any
+	}{
+		{"counter", `
+			fun makeCounter() {
+				var i = 0;
+				fun count() {
+					i = i + 1;
+					return i;
+				}
+				return count;
+			}
+			var a = makeCounter();
+			var b = makeCounter();
+			a(); a(); b();
+			a();`, 3.0},
+		{"nested", `
+			fun outer() {
+				var x = "outer";
+				fun middle() {
+					fun inner() { return x; }
+					return inner;
+				}
+				return middle;
+			}
+			outer()()();`, "outer"},
+		{"shared", `
+			fun pair() {
+				var n = 0;
+				var get = fun () { return n; };
+				fun set(v) { n = v; }
+				set(42);
+				return get;
+			}
+			pair()();`, 42.0},
+		{"lexical scope", `
+			var a = "global";
+			var first;
+			var second;
+			{
+				fun show() { return a; }
+				first = show();
+				var a = "block";
+				second = show();
+			}
+			first + second;`, "globalglobal"},
+		{"loop body", `
+			var first;
+			var second;
+			for (var i = 0; i < 2; i = i + 1) {
+				var j = i;
+				fun f() { return j; }
+				if (first == nil) first = f; else second = f;
+			}
+			first() * 10 + second();`, 1.0},
+		{"loop variable", `
+			var first;
+			var second;
+			for (var i = 0; i < 2; i = i + 1) {
+				fun f() { return i; }
+				if (first == nil) first = f; else second = f;
+			}
+			first() * 10 + second();`, 22.0},
+		{"method", `
+			fun make() {
+				var greeting = "hi ";
+				class Greeter {
+					greet(name) { return greeting + name; }
+				}
+				return Greeter();
+			}
+			make().greet("bob");`, "hi bob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(t, tt.source); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
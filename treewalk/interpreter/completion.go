@@ -0,0 +1,29 @@
+package interpreter
+
+// completionKind says how a statement finished.
+type completionKind int
+
+const (
+	COMPLETION_NORMAL completionKind = iota
+	COMPLETION_RETURN
+	COMPLETION_BREAK
+	COMPLETION_CONTINUE
+	COMPLETION_ERROR
+)
+
+// completion is the result of executing a statement. Statements that end
+// abruptly pass their completion up to the statement that handles it: a
+// function call for COMPLETION_RETURN, a loop for COMPLETION_BREAK and
+// COMPLETION_CONTINUE, and a try statement or the top level for
+// COMPLETION_ERROR. The zero value is a normal completion.
+type completion struct {
+	kind completionKind
+	// value is the returned value of COMPLETION_RETURN.
+	value any
+	// err is set for COMPLETION_ERROR.
+	err error
+}
+
+func failed(err error) completion {
+	return completion{kind: COMPLETION_ERROR, err: err}
+}
@@ -0,0 +1,99 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"lox/treewalk/ast"
+	"lox/treewalk/astprinter"
+	"lox/treewalk/env"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/token"
+	"strings"
+)
+
+// ThrowError is behind the runtime error raised by a throw statement. Value
+// is what was thrown.
+type ThrowError struct {
+	Value any
+}
+
+func (e *ThrowError) Error() string {
+	if loxErr, ok := e.Value.(*LoxError); ok {
+		return loxErr.Message
+	}
+	return astprinter.Stringify(e.Value)
+}
+
+// LoxError is an error as a Lox value, with the properties message, line
+// and stack. Error(message) creates one for programs to throw, and a catch
+// clause receives one for errors raised by the interpreter itself.
+type LoxError struct {
+	Message string
+	// Line is where the error was raised or first thrown, or 0 if it has
+	// not been yet.
+	Line  int
+	Trace []loxerrors.Frame
+}
+
+func (e *LoxError) get(name token.Token) (any, error) {
+	switch name.Lexeme {
+	case "message":
+		return e.Message, nil
+	case "line":
+		if e.Line == 0 {
+			return nil, nil
+		}
+		return float64(e.Line), nil
+	case "stack":
+		lines := make([]string, len(e.Trace))
+		for i, frame := range e.Trace {
+			lines[i] = frame.String()
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	return nil, loxerrors.NewErrorRuntime(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (e *LoxError) String() string {
+	return "Error: " + e.Message
+}
+
+// newError is the native Error(message).
+func newError(interpreter *Interpreter, arguments []any) (any, error) {
+	return &LoxError{Message: astprinter.Stringify(arguments[0])}, nil
+}
+
+// caught returns the value a catch clause receives for err, if err is an
+// error the program may catch. Running out of steps or memory, or being
+// canceled, cannot be caught: the program has to stop.
+func (i *Interpreter) caught(err error) (any, bool) {
+	runtimeErr, ok := err.(*loxerrors.ErrorRuntime)
+	if !ok {
+		return nil, false
+	}
+	for _, limit := range []error{ErrStepLimit, ErrMemoryLimit, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(err, limit) {
+			return nil, false
+		}
+	}
+
+	i.traced(runtimeErr)
+	var thrown *ThrowError
+	if errors.As(err, &thrown) {
+		if loxErr, ok := thrown.Value.(*LoxError); ok && loxErr.Trace == nil {
+			loxErr.Trace = runtimeErr.Trace()
+		}
+		return thrown.Value, true
+	}
+	return &LoxError{Message: runtimeErr.Error(), Line: runtimeErr.Line(), Trace: runtimeErr.Trace()}, true
+}
+
+// catch runs the catch clause of stmt with value bound to its variable.
+func (i *Interpreter) catch(stmt *ast.Try, value any) completion {
+	if err := i.allocate(ENVIRONMENT_SIZE, stmt.CatchBody.Span()); err != nil {
+		return failed(err)
+	}
+	environment := env.New(i.environment)
+	environment.Define(stmt.CatchName.Lexeme, value)
+	return i.executeBlock(stmt.CatchBody.Statements, environment)
+}
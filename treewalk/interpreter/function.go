@@ -1,9 +1,9 @@
 package interpreter
 
 import (
-	"fmt"
 	"lox/treewalk/ast"
 	"lox/treewalk/env"
+	"lox/treewalk/token"
 	"time"
 )
 
@@ -13,38 +13,59 @@ type LoxCallable interface {
 }
 
 type LoxFunction struct {
-	declaration *ast.Function
+	name          string
+	params        []token.Token
+	body          []ast.Stmt
+	closure       *env.Environment
+	isInitializer bool
 }
 
-func (fn LoxFunction) call(interpreter *Interpreter, arguments []any) (returnValue any) {
-	// globals := env.Copy(interpreter.globals)
-	environment := env.New(interpreter.loxerror, interpreter.globals)
-	for i, param := range fn.declaration.Params {
+func NewLoxFunction(declaration *ast.Function, closure *env.Environment, isInitializer bool) *LoxFunction {
+	return &LoxFunction{declaration.Name.Lexeme, declaration.Params, declaration.Body, closure, isInitializer}
+}
+
+func NewLoxLambda(lambda *ast.Lambda, closure *env.Environment) *LoxFunction {
+	return &LoxFunction{"", lambda.Params, lambda.Body, closure, false}
+}
+
+func (fn *LoxFunction) bind(instance *LoxInstance) *LoxFunction {
+	environment := env.New(fn.closure)
+	environment.Define("this", instance)
+	return &LoxFunction{fn.name, fn.params, fn.body, environment, fn.isInitializer}
+}
+
+func (fn *LoxFunction) call(interpreter *Interpreter, arguments []any) any {
+	environment := env.New(fn.closure)
+	for i, param := range fn.params {
 		environment.Define(param.Lexeme, arguments[i])
 	}
 
-	defer func() {
-		if err := recover(); err != nil {
-			if v, ok := err.(Return); ok {
-				fmt.Println(v)
-				returnValue = v.value
-				return
-			} else {
-				panic(err)
-			}
-		}
-	}()
+	name := fn.name
+	if name == "" {
+		name = "anonymous"
+	}
+	interpreter.pushFrame(name)
+	defer interpreter.popFrame()
 
-	interpreter.executeBlock(fn.declaration.Body, environment)
-	return returnValue
+	result := interpreter.executeBlock(fn.body, environment)
+	if result.kind == COMPLETION_ERROR {
+		return interpreter.traced(result.err)
+	}
+	if fn.isInitializer {
+		return fn.closure.GetAt(0, "this")
+	}
+	return result.value
 }
 
-func (fn LoxFunction) arity() int {
-	return len(fn.declaration.Params)
+func (fn *LoxFunction) arity() int {
+	return len(fn.params)
 }
 
-func (fn LoxFunction) String() string {
-	return "<fn " + fn.declaration.Name.Lexeme + ">"
+func (fn *LoxFunction) String() string {
+	if fn.name == "" {
+		return "<fn anonymous>"
+	}
+	return "<fn " + fn.name + ">"
 }
 
 type Clock struct{}
@@ -54,9 +75,12 @@ func (Clock) arity() int {
 }
 
 func (Clock) call(interpreter *Interpreter, arguments []any) any {
-	return float64(time.Now().UnixMilli() / 1000.0)
+	if err := interpreter.require(CAP_CLOCK, "clock"); err != nil {
+		return nativeError{err}
+	}
+	return float64(time.Now().UnixNano()) / 1e9
 }
 
 func (Clock) String() string {
-	return "<native fn Clock>"
+	return "<native fn clock>"
 }
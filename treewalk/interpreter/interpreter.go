@@ -1,53 +1,135 @@
 package interpreter
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"lox/treewalk/ast"
 	"lox/treewalk/astprinter"
 	"lox/treewalk/env"
 	"lox/treewalk/loxerrors"
 	"lox/treewalk/token"
-	"reflect"
+	"os"
+	"strings"
 )
 
-type Return struct {
-	value any
-}
-
-func (r Return) Error() string {
-	return fmt.Sprintf("%v", r.value)
-}
-
 type Interpreter struct {
 	loxerror    *loxerrors.LoxErrors
 	environment *env.Environment
 	globals     *env.Environment
+	locals      map[ast.Expr]int
+	limits      Limits
+	budget      budget
+	frames      []loxerrors.Frame
+	stdout      io.Writer
+
+	capabilities Capabilities
+
+	// callSpan is the span of the innermost call, where natives charge
+	// what they allocate.
+	callSpan token.Span
 }
 
 func New(loxerror *loxerrors.LoxErrors) *Interpreter {
-	globals := env.New(loxerror, nil)
-	globals.Define("clock", Clock{})
-	return &Interpreter{loxerror: loxerror, environment: globals, globals: globals}
+	globals := env.New(nil)
+	defineStdlib(globals)
+	i := &Interpreter{loxerror: loxerror, environment: globals, globals: globals, locals: make(map[ast.Expr]int), stdout: os.Stdout}
+	i.SetLimits(Limits{})
+	i.begin(context.Background())
+	return i
 }
 
-func (i *Interpreter) Interpret(statements []ast.Stmt) (any, error) {
-	var value any
+// Interpret runs statements until they finish, fail, or ctx is done. The
+// limits apply afresh to each call.
+func (i *Interpreter) Interpret(ctx context.Context, statements []ast.Stmt) (any, error) {
+	i.begin(ctx)
+	i.frames = []loxerrors.Frame{{}}
+
+	for k, statement := range statements {
+		// A trailing expression statement is evaluated for its value.
+		if expression, ok := statement.(*ast.Expression); ok && k == len(statements)-1 {
+			value := i.evalute(expression.Expression)
+			if err, ok := value.(error); ok {
+				return nil, i.report(i.traced(err))
+			}
+			return value, nil
+		}
 
-	for _, statement := range statements {
-		value = i.execute(statement)
-		if err, ok := value.(error); ok {
-			return nil, err
+		if result := i.execute(statement); result.kind == COMPLETION_ERROR {
+			return nil, i.report(i.traced(result.err))
 		}
 	}
+	return nil, nil
+}
+
+// SetOutput sends the output of print statements to stdout. Errors go to
+// the sink of the interpreter's LoxErrors.
+func (i *Interpreter) SetOutput(stdout io.Writer) {
+	i.stdout = stdout
+}
 
+// DefineGlobal binds name in the global scope, replacing any old value.
+func (i *Interpreter) DefineGlobal(name string, value any) {
+	i.globals.Define(name, value)
+}
+
+// Global looks name up in the global scope.
+func (i *Interpreter) Global(name string) (any, bool) {
+	value, ok := i.globals.Values[name]
+	return value, ok
+}
+
+// Call invokes a Lox function or class from Go, under ctx and a fresh
+// budget as Interpret does. Errors raised while it runs are reported like
+// any other runtime error and also returned.
+func (i *Interpreter) Call(ctx context.Context, callee any, arguments []any) (any, error) {
+	i.begin(ctx)
+	i.frames = nil
+	value, err := i.callValue(callee, arguments)
+	if err != nil {
+		return nil, i.report(err)
+	}
 	return value, nil
 }
 
-func (i *Interpreter) execute(stmt ast.Stmt) any {
-	return stmt.Accept(i)
+// callValue is Call for Go code running inside the program, such as a
+// native calling back into Lox.
+func (i *Interpreter) callValue(callee any, arguments []any) (any, error) {
+	function, ok := callee.(LoxCallable)
+	if !ok {
+		return nil, fmt.Errorf("can only call functions and classes, not %s", astprinter.Stringify(callee))
+	}
+	if message := checkArity(function, len(arguments)); message != "" {
+		return nil, errors.New(message)
+	}
+
+	if err := i.enterCall(token.Span{}); err != nil {
+		return nil, err
+	}
+	defer i.leaveCall()
+
+	value := function.call(i, arguments)
+	if err, ok := value.(error); ok {
+		return nil, err
+	}
+	return value, nil
 }
 
-func (i *Interpreter) executeBlock(statements []ast.Stmt, environemt *env.Environment) {
+func (i *Interpreter) Resolve(exp ast.Expr, depth int) {
+	i.locals[exp] = depth
+}
+
+func (i *Interpreter) execute(stmt ast.Stmt) completion {
+	if err := i.step(stmt.Span()); err != nil {
+		return failed(err)
+	}
+	return stmt.Accept(i).(completion)
+}
+
+// executeBlock runs statements in environment until one of them ends
+// abruptly.
+func (i *Interpreter) executeBlock(statements []ast.Stmt, environemt *env.Environment) completion {
 	previous := i.environment
 	defer func() {
 		i.environment = previous
@@ -55,96 +137,199 @@ func (i *Interpreter) executeBlock(statements []ast.Stmt, environemt *env.Enviro
 	i.environment = environemt
 
 	for _, statement := range statements {
-		i.execute(statement)
-		// value := i.execute(statement)
-		// if _, ok := value.(error); ok {
-		// 	i.environment = previous
-		// 	return
-		// }
+		if result := i.execute(statement); result.kind != COMPLETION_NORMAL {
+			return result
+		}
 	}
-
+	return completion{}
 }
 
 func (i *Interpreter) VisitBlockStmt(stmt *ast.Block) any {
-	i.executeBlock(stmt.Statements, env.New(i.loxerror, i.environment))
-	return nil
+	if err := i.allocate(ENVIRONMENT_SIZE, stmt.Span()); err != nil {
+		return failed(err)
+	}
+	return i.executeBlock(stmt.Statements, env.New(i.environment))
+}
+
+func (i *Interpreter) VisitClassStmt(stmt *ast.Class) any {
+	if err := i.allocate(FUNCTION_SIZE*(len(stmt.Methods)+1), stmt.Span()); err != nil {
+		return failed(err)
+	}
+	i.environment.Define(stmt.Name.Lexeme, nil)
+
+	methods := make(map[string]*LoxFunction)
+	for _, method := range stmt.Methods {
+		methods[method.Name.Lexeme] = NewLoxFunction(method, i.environment, method.Name.Lexeme == "init")
+	}
+
+	class := NewLoxClass(stmt.Name.Lexeme, methods)
+	if err := i.environment.Assign(stmt.Name, class); err != nil {
+		return failed(err)
+	}
+	return completion{}
 }
 
 func (i *Interpreter) VisitVarStmt(stmt *ast.Var) any {
 	var value any
 	if stmt.Initializer != nil {
 		value = i.evalute(stmt.Initializer)
+		if err, ok := value.(error); ok {
+			return failed(err)
+		}
 	}
 
 	i.environment.Define(stmt.Name.Lexeme, value)
-	return nil
+	return completion{}
 }
 
 func (i *Interpreter) VisitIfStmt(stmt *ast.If) any {
-	if isTruthy(i.evalute(stmt.Condition)) {
-		i.execute(stmt.ThenBranch)
+	condition := i.evalute(stmt.Condition)
+	if err, ok := condition.(error); ok {
+		return failed(err)
+	}
+
+	if isTruthy(condition) {
+		return i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
-		i.execute(stmt.ElseBranch)
+		return i.execute(stmt.ElseBranch)
 	}
-	return nil
+	return completion{}
 }
 
 func (i *Interpreter) VisitWhileStmt(stmt *ast.While) any {
-	for isTruthy(i.evalute(stmt.Condition)) {
-		i.execute(stmt.Body)
+	for {
+		condition := i.evalute(stmt.Condition)
+		if err, ok := condition.(error); ok {
+			return failed(err)
+		}
+		if !isTruthy(condition) {
+			break
+		}
+
+		switch result := i.execute(stmt.Body); result.kind {
+		case COMPLETION_BREAK:
+			return completion{}
+		case COMPLETION_RETURN, COMPLETION_ERROR:
+			return result
+		}
+
+		if stmt.Increment != nil {
+			if err, ok := i.evalute(stmt.Increment).(error); ok {
+				return failed(err)
+			}
+		}
 	}
 
-	return nil
+	return completion{}
+}
+
+func (i *Interpreter) VisitBreakStmt(stmt *ast.Break) any {
+	return completion{kind: COMPLETION_BREAK}
+}
+
+func (i *Interpreter) VisitContinueStmt(stmt *ast.Continue) any {
+	return completion{kind: COMPLETION_CONTINUE}
 }
 
 func (i *Interpreter) VisitExpressionStmt(stmt *ast.Expression) any {
-	value := i.evalute(stmt.Expression)
-	return value
+	if err, ok := i.evalute(stmt.Expression).(error); ok {
+		return failed(err)
+	}
+	return completion{}
 }
 
 func (i *Interpreter) VisitFunctionStmt(stmt *ast.Function) any {
-	function := LoxFunction{stmt}
+	if err := i.allocate(FUNCTION_SIZE, stmt.Span()); err != nil {
+		return failed(err)
+	}
+	function := NewLoxFunction(stmt, i.environment, false)
 	i.environment.Define(stmt.Name.Lexeme, function)
-	return nil
+	return completion{}
 }
 
 func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) any {
 	value := i.evalute(stmt.Expression)
 	if err, ok := value.(error); ok {
-		return err
+		return failed(err)
 	}
-	fmt.Println(astprinter.Stringify(value))
-	return nil
+	fmt.Fprintln(i.stdout, astprinter.Stringify(value))
+	return completion{}
 }
 
 func (i *Interpreter) VisitReturnStmt(stmt *ast.Return) any {
 	var value any
-	fmt.Println("type is: ", reflect.TypeOf(stmt))
 	if stmt.Value != nil {
-		fmt.Println("type of value: ", reflect.TypeOf(stmt.Value))
-		fmt.Printf("value of stmt value: %#v\n", stmt.Value)
-
 		value = i.evalute(stmt.Value)
+		if err, ok := value.(error); ok {
+			return failed(err)
+		}
+	}
+	return completion{kind: COMPLETION_RETURN, value: value}
+}
+
+func (i *Interpreter) VisitThrowStmt(stmt *ast.Throw) any {
+	value := i.evalute(stmt.Value)
+	if err, ok := value.(error); ok {
+		return failed(err)
+	}
+
+	if loxErr, ok := value.(*LoxError); ok && loxErr.Line == 0 {
+		loxErr.Line = stmt.Keyword.Line
+	}
+	thrown := &ThrowError{value}
+	return failed(loxerrors.NewErrorRuntimeAt(stmt.Keyword, stmt.Span(), thrown.Error()).Wrap(thrown))
+}
+
+// VisitTryStmt runs the finally clause however the try and catch clauses
+// end. If the finally clause itself ends abruptly, by returning or with an
+// error, that replaces how they ended.
+func (i *Interpreter) VisitTryStmt(stmt *ast.Try) any {
+	result := i.execute(stmt.Body)
+	if stmt.CatchBody != nil && result.kind == COMPLETION_ERROR {
+		if value, ok := i.caught(result.err); ok {
+			result = i.catch(stmt, value)
+		}
+	}
+
+	if stmt.FinallyBody != nil {
+		if finally := i.execute(stmt.FinallyBody); finally.kind != COMPLETION_NORMAL {
+			return finally
+		}
 	}
-	// fmt.Println("value is: ", value)
-	panic(Return{value})
+	return result
 }
 
 func (i *Interpreter) evalute(exp ast.Expr) any {
+	if err := i.step(exp.Span()); err != nil {
+		return err
+	}
 	return exp.Accept(i)
 }
 
 func (i *Interpreter) VisitAssignExpr(exp *ast.Assign) any {
 	value := i.evalute(exp.Value)
-	err := i.environment.Assign(exp.Name, value)
-	if err != nil {
+	if err, ok := value.(error); ok {
+		return err
+	}
+
+	if distance, ok := i.locals[exp]; ok {
+		i.environment.AssignAt(distance, exp.Name, value)
+	} else if err := i.globals.Assign(exp.Name, value); err != nil {
 		return err
 	}
 	return value
 }
 
 func (i *Interpreter) VisitVariableExpr(exp *ast.Variable) any {
-	value, err := i.environment.Get(exp.Name)
+	return i.lookupVariable(exp.Name, exp)
+}
+
+func (i *Interpreter) lookupVariable(name token.Token, exp ast.Expr) any {
+	if distance, ok := i.locals[exp]; ok {
+		return i.environment.GetAt(distance, name.Lexeme)
+	}
+
+	value, err := i.globals.Get(name)
 	if err != nil {
 		return err
 	}
@@ -157,6 +342,9 @@ func (i *Interpreter) VisitLiteralExpr(exp *ast.Literal) any {
 
 func (i *Interpreter) VisitLogicalExpr(exp *ast.Logical) any {
 	left := i.evalute(exp.Left)
+	if err, ok := left.(error); ok {
+		return err
+	}
 
 	if exp.Operator.Typ == token.OR {
 		if isTruthy(left) {
@@ -177,6 +365,9 @@ func (i *Interpreter) VisitGroupingExpr(exp *ast.Grouping) any {
 
 func (i *Interpreter) VisitUnaryExpr(exp *ast.Unary) any {
 	right := i.evalute(exp.Right)
+	if err, ok := right.(error); ok {
+		return err
+	}
 	op := exp.Operator
 	switch op.Typ {
 	case token.BANG:
@@ -185,15 +376,11 @@ func (i *Interpreter) VisitUnaryExpr(exp *ast.Unary) any {
 		if r, ok := right.(float64); ok {
 			return -r
 		} else {
-			err := loxerrors.NewErrorRuntime(op, "Operand must be a number")
-			i.loxerror.RuntimeError(err)
-			return err
+			return loxerrors.NewErrorRuntimeAt(op, exp.Right.Span(), "Operand must be a number.")
 		}
 	}
 
-	err := loxerrors.NewErrorRuntime(op, "Unreachable")
-	i.loxerror.RuntimeError(err)
-	return err
+	return loxerrors.NewErrorRuntime(op, "Unreachable")
 }
 
 func (i *Interpreter) VisitBinaryExpr(exp *ast.Binary) any {
@@ -201,8 +388,6 @@ func (i *Interpreter) VisitBinaryExpr(exp *ast.Binary) any {
 	if err, ok := left.(error); ok {
 		return err
 	}
-	// fmt.Println("type of binary right: ", reflect.TypeOf(exp.Right))
-	// fmt.Printf("value of binary right: %#v\n", exp.Right)
 	right := i.evalute(exp.Right)
 	if err, ok := right.(error); ok {
 		return err
@@ -222,14 +407,18 @@ func (i *Interpreter) VisitBinaryExpr(exp *ast.Binary) any {
 				l, ok1 := left.(string)
 				r, ok2 := right.(string)
 				if ok1 && ok2 {
+					if err := i.allocate(len(l)+len(r), exp.Span()); err != nil {
+						return err
+					}
 					return l + r
 				}
 			}
 
-			err := loxerrors.NewErrorRuntime(op, "Operands must be two numbers or two strings.")
-			i.loxerror.RuntimeError(err)
-
-			return err
+			message := "Operands must be numbers."
+			if op.Typ == token.PLUS {
+				message = "Operands must be two numbers or two strings."
+			}
+			return loxerrors.NewErrorRuntimeAt(op, exp.Span(), message)
 		}
 
 		switch op.Typ {
@@ -242,14 +431,8 @@ func (i *Interpreter) VisitBinaryExpr(exp *ast.Binary) any {
 		case token.LESS_EQUAL:
 			return l <= r
 		case token.MINUS:
-			fmt.Println("minus")
-			fmt.Println("l is", l)
-			fmt.Println("r is", r)
 			return l - r
 		case token.PLUS:
-			fmt.Println("plus")
-			fmt.Println("l is", l)
-			fmt.Println("r is", r)
 			return l + r
 		case token.SLASH:
 			return l / r
@@ -258,33 +441,244 @@ func (i *Interpreter) VisitBinaryExpr(exp *ast.Binary) any {
 		}
 	}
 
-	err := loxerrors.NewErrorRuntime(op, "Unreachable")
-	i.loxerror.RuntimeError(err)
-	return err
+	return loxerrors.NewErrorRuntime(op, "Unreachable")
 }
 
 func (i *Interpreter) VisitCallExpr(exp *ast.Call) any {
 	callee := i.evalute(exp.Callee)
+	if err, ok := callee.(error); ok {
+		return err
+	}
 
 	var arguments []any
 	for _, argument := range exp.Arguments {
-		arguments = append(arguments, i.evalute(argument))
+		value := i.evalute(argument)
+		if err, ok := value.(error); ok {
+			return err
+		}
+		arguments = append(arguments, value)
 	}
 
 	function, ok := callee.(LoxCallable)
 	if !ok {
-		err := loxerrors.NewErrorRuntime(exp.Paren, "Can only call functions and classes.")
-		i.loxerror.RuntimeError(err)
+		return loxerrors.NewErrorRuntimeAt(exp.Paren, exp.Callee.Span(), "Can only call functions and classes.")
+	}
+
+	if message := checkArity(function, len(arguments)); message != "" {
+		return loxerrors.NewErrorRuntimeAt(exp.Paren, exp.Span(), message)
+	}
+
+	size := ENVIRONMENT_SIZE
+	if _, ok := function.(*LoxClass); ok {
+		size += INSTANCE_SIZE
+	}
+	if err := i.allocate(size, exp.Span()); err != nil {
+		return err
+	}
+	if err := i.enterCall(exp.Span()); err != nil {
+		return err
+	}
+	i.callingFrom(exp.Paren.Line)
+	outer := i.callSpan
+	i.callSpan = exp.Span()
+	value := function.call(i, arguments)
+	i.callSpan = outer
+	i.leaveCall()
+
+	if native, ok := value.(nativeError); ok {
+		// Errors from Lox code the native called back into already carry
+		// their trace, and exiting is not an error.
+		var reported *loxerrors.ErrorRuntime
+		if errors.As(native, &reported) {
+			return reported
+		}
+		var exit *ExitError
+		if errors.As(native, &exit) {
+			return exit
+		}
+		return loxerrors.NewErrorRuntimeAt(exp.Paren, exp.Span(), native.Error()).Wrap(native.err)
+	}
+	return value
+}
+
+func (i *Interpreter) VisitGetExpr(exp *ast.Get) any {
+	object := i.evalute(exp.Object)
+	if err, ok := object.(error); ok {
+		return err
+	}
+
+	if loxErr, ok := object.(*LoxError); ok {
+		value, err := loxErr.get(exp.Name)
+		if err != nil {
+			return err
+		}
+		return value
+	}
+
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		return loxerrors.NewErrorRuntimeAt(exp.Name, exp.Object.Span(), "Only instances have properties.")
+	}
+
+	value, err := instance.Get(exp.Name)
+	if err != nil {
+		return err
+	}
+	return value
+}
+
+func (i *Interpreter) VisitListExpr(exp *ast.List) any {
+	elements := make([]any, len(exp.Elements))
+	for k, element := range exp.Elements {
+		value := i.evalute(element)
+		if err, ok := value.(error); ok {
+			return err
+		}
+		elements[k] = value
+	}
+
+	if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*len(elements), exp.Span()); err != nil {
+		return err
+	}
+	return NewLoxList(elements)
+}
+
+func (i *Interpreter) VisitMapExpr(exp *ast.Map) any {
+	if err := i.allocate(MAP_SIZE+ENTRY_SIZE*len(exp.Keys), exp.Span()); err != nil {
+		return err
+	}
+
+	m := NewLoxMap()
+	for k, key := range exp.Keys {
+		keyValue := i.evalute(key)
+		if err, ok := keyValue.(error); ok {
+			return err
+		}
+		value := i.evalute(exp.Values[k])
+		if err, ok := value.(error); ok {
+			return err
+		}
+		if err := checkKey(keyValue); err != nil {
+			return loxerrors.NewErrorRuntimeAt(exp.Brace, key.Span(), err.Error())
+		}
+		m.set(keyValue, value)
+	}
+	return m
+}
+
+func (i *Interpreter) VisitInterpolationExpr(exp *ast.Interpolation) any {
+	var b strings.Builder
+	for _, part := range exp.Parts {
+		value := i.evalute(part)
+		if err, ok := value.(error); ok {
+			return err
+		}
+		b.WriteString(astprinter.Stringify(value))
+	}
+
+	if err := i.allocate(b.Len(), exp.Span()); err != nil {
+		return err
+	}
+	return b.String()
+}
+
+func (i *Interpreter) VisitIndexExpr(exp *ast.Index) any {
+	object := i.evalute(exp.Object)
+	if err, ok := object.(error); ok {
+		return err
+	}
+	index := i.evalute(exp.Index)
+	if err, ok := index.(error); ok {
+		return err
+	}
+
+	switch object := object.(type) {
+	case *LoxList:
+		k, err := object.index(index)
+		if err != nil {
+			return loxerrors.NewErrorRuntimeAt(exp.Bracket, exp.Index.Span(), err.Error())
+		}
+		return object.elements[k]
+	case *LoxMap:
+		value, err := object.get(index)
+		if err != nil {
+			return loxerrors.NewErrorRuntimeAt(exp.Bracket, exp.Index.Span(), err.Error())
+		}
+		return value
+	}
+	return loxerrors.NewErrorRuntimeAt(exp.Bracket, exp.Object.Span(), "Only lists and maps can be indexed.")
+}
+
+func (i *Interpreter) VisitSetIndexExpr(exp *ast.SetIndex) any {
+	object := i.evalute(exp.Object)
+	if err, ok := object.(error); ok {
+		return err
+	}
+	index := i.evalute(exp.Index)
+	if err, ok := index.(error); ok {
+		return err
+	}
+	value := i.evalute(exp.Value)
+	if err, ok := value.(error); ok {
+		return err
+	}
+
+	switch object := object.(type) {
+	case *LoxList:
+		k, err := object.index(index)
+		if err != nil {
+			return loxerrors.NewErrorRuntimeAt(exp.Bracket, exp.Index.Span(), err.Error())
+		}
+		object.elements[k] = value
+		return value
+	case *LoxMap:
+		if err := checkKey(index); err != nil {
+			return loxerrors.NewErrorRuntimeAt(exp.Bracket, exp.Index.Span(), err.Error())
+		}
+		if !object.has(index) {
+			if err := i.allocate(ENTRY_SIZE, exp.Span()); err != nil {
+				return err
+			}
+		}
+		object.set(index, value)
+		return value
+	}
+	return loxerrors.NewErrorRuntimeAt(exp.Bracket, exp.Object.Span(), "Only lists and maps can be indexed.")
+}
+
+func (i *Interpreter) VisitSetExpr(exp *ast.Set) any {
+	object := i.evalute(exp.Object)
+	if err, ok := object.(error); ok {
+		return err
+	}
+
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		return loxerrors.NewErrorRuntimeAt(exp.Name, exp.Object.Span(), "Only instances have fields.")
+	}
+
+	value := i.evalute(exp.Value)
+	if err, ok := value.(error); ok {
 		return err
 	}
+	if _, ok := instance.fields[exp.Name.Lexeme]; !ok {
+		if err := i.allocate(FIELD_SIZE, exp.Span()); err != nil {
+			return err
+		}
+	}
+	instance.Set(exp.Name, value)
+	return value
+}
 
-	if want, got := function.arity(), len(arguments); want != got {
-		err := loxerrors.NewErrorRuntime(exp.Paren, fmt.Sprintf("Expected %d arguments but got %d.", want, got))
-		i.loxerror.RuntimeError(err)
+func (i *Interpreter) VisitLambdaExpr(exp *ast.Lambda) any {
+	if err := i.allocate(FUNCTION_SIZE, exp.Span()); err != nil {
 		return err
 	}
+	return NewLoxLambda(exp, i.environment)
+}
 
-	return function.call(i, arguments)
+func (i *Interpreter) VisitThisExpr(exp *ast.This) any {
+	return i.lookupVariable(exp.Keyword, exp)
 }
 
 func isTruthy(o any) bool {
@@ -0,0 +1,43 @@
+package interpreter_test
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLambdas(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   any
+	}{
+		{"argument", `
+			fun twice(f, x) { return f(f(x)); }
+			twice(fun (n) { return n * 3; }, 2);`, 18.0},
+		{"immediate call", `(fun (a, b) { return a - b; })(5, 3);`, 2.0},
+		{"closes over local", `
+			fun counter() {
+				var n = 0;
+				return fun () { n = n + 1; return n; };
+			}
+			var c = counter();
+			c();
+			c();`, 2.0},
+		{"no return value", `(fun () {})();`, nil},
+		{"declaration still named", `
+			fun named() { return "named"; }
+			named();`, "named"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(t, tt.source); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := fmt.Sprint(run(t, `fun () {};`)); got != "<fn anonymous>" {
+		t.Errorf("lambda prints as %q, want %q", got, "<fn anonymous>")
+	}
+}
@@ -0,0 +1,113 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/token"
+)
+
+// DEFAULT_MAX_CALL_DEPTH keeps deep recursion well clear of overflowing the
+// Go stack, which would crash the host process.
+const DEFAULT_MAX_CALL_DEPTH = 10000
+
+// The context is polled once every CONTEXT_CHECK_INTERVAL steps.
+const CONTEXT_CHECK_INTERVAL = 1024
+
+// Approximate sizes charged against Limits.MaxMemory.
+const (
+	ENVIRONMENT_SIZE = 64
+	FUNCTION_SIZE    = 64
+	INSTANCE_SIZE    = 64
+	FIELD_SIZE       = 32
+	LIST_SIZE        = 32
+	ELEMENT_SIZE     = 16
+	MAP_SIZE         = 48
+	ENTRY_SIZE       = 32
+)
+
+// Errors behind the runtime errors raised when a limit is hit, for hosts to
+// test for with errors.Is. Cancellation wraps the context's own error.
+var (
+	ErrStepLimit   = errors.New("step limit exceeded")
+	ErrCallDepth   = errors.New("call depth limit exceeded")
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+)
+
+// Limits bounds the resources one call to Interpret may use. A zero field
+// means no limit, except MaxCallDepth, which then defaults to
+// DEFAULT_MAX_CALL_DEPTH.
+type Limits struct {
+	// MaxSteps bounds the statements executed plus expressions evaluated.
+	MaxSteps int
+	// MaxCallDepth bounds how deeply calls may nest.
+	MaxCallDepth int
+	// MaxMemory bounds the bytes allocated for strings, lists, maps,
+	// environments, functions and instances, including those built by
+	// natives. It is an estimate, and memory is not given back when the
+	// garbage collector frees it.
+	MaxMemory int
+}
+
+// budget is what the running program has used so far.
+type budget struct {
+	ctx    context.Context
+	steps  int
+	depth  int
+	memory int
+}
+
+func (i *Interpreter) SetLimits(limits Limits) {
+	if limits.MaxCallDepth == 0 {
+		limits.MaxCallDepth = DEFAULT_MAX_CALL_DEPTH
+	}
+	i.limits = limits
+}
+
+// begin starts a fresh budget for a run under ctx.
+func (i *Interpreter) begin(ctx context.Context) {
+	i.budget = budget{ctx: ctx}
+}
+
+// step counts one unit of work done at span and checks the step limit and
+// the context.
+func (i *Interpreter) step(span token.Span) error {
+	i.budget.steps++
+	if i.limits.MaxSteps > 0 && i.budget.steps > i.limits.MaxSteps {
+		return loxerrors.NewErrorRuntimeCause(span, "Step limit exceeded.", ErrStepLimit)
+	}
+
+	if i.budget.steps%CONTEXT_CHECK_INTERVAL == 0 {
+		if err := i.budget.ctx.Err(); err != nil {
+			message := "Execution canceled."
+			if errors.Is(err, context.DeadlineExceeded) {
+				message = "Execution timed out."
+			}
+			return loxerrors.NewErrorRuntimeCause(span, message, err)
+		}
+	}
+	return nil
+}
+
+// enterCall records a call made at span. Every successful enterCall must be
+// paired with a leaveCall.
+func (i *Interpreter) enterCall(span token.Span) error {
+	if i.budget.depth >= i.limits.MaxCallDepth {
+		return loxerrors.NewErrorRuntimeCause(span, "Stack overflow.", ErrCallDepth)
+	}
+	i.budget.depth++
+	return nil
+}
+
+func (i *Interpreter) leaveCall() {
+	i.budget.depth--
+}
+
+// allocate charges size bytes allocated at span against the memory limit.
+func (i *Interpreter) allocate(size int, span token.Span) error {
+	i.budget.memory += size
+	if i.limits.MaxMemory > 0 && i.budget.memory > i.limits.MaxMemory {
+		return loxerrors.NewErrorRuntimeCause(span, "Memory limit exceeded.", ErrMemoryLimit)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package interpreter
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"lox/treewalk/astprinter"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LoxList is a Lox list. Like instances, lists are mutable and shared by
+// reference.
+type LoxList struct {
+	elements []any
+}
+
+func NewLoxList(elements []any) *LoxList {
+	return &LoxList{elements: elements}
+}
+
+// index checks a Lox value used to index the list and returns the position
+// it refers to. Negative indices count back from the end.
+func (l *LoxList) index(value any) (int, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return 0, errors.New("List index must be a number.")
+	}
+	if n != math.Trunc(n) {
+		return 0, errors.New("List index must be an integer.")
+	}
+
+	index := n
+	if index < 0 {
+		index += float64(len(l.elements))
+	}
+	if index < 0 || index >= float64(len(l.elements)) {
+		return 0, fmt.Errorf("Index %s is out of range for a list of length %d.", astprinter.Stringify(n), len(l.elements))
+	}
+	return int(index), nil
+}
+
+func (l *LoxList) String() string {
+	return format(l, nil)
+}
+
+// format formats value as print shows it. enclosing holds the lists and
+// maps being formatted around value, so that a list inside itself prints as
+// [...] and a map inside itself as {...}.
+func format(value any, enclosing []any) string {
+	switch v := value.(type) {
+	case *LoxList:
+		if slices.Contains(enclosing, any(v)) {
+			return "[...]"
+		}
+		enclosing = append(enclosing, v)
+
+		elements := make([]string, len(v.elements))
+		for i, element := range v.elements {
+			elements[i] = formatElement(element, enclosing)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *LoxMap:
+		if slices.Contains(enclosing, any(v)) {
+			return "{...}"
+		}
+		enclosing = append(enclosing, v)
+
+		entries := make([]string, len(v.keys))
+		for i, key := range v.keys {
+			entries[i] = formatElement(key, enclosing) + ": " + formatElement(v.entries[key], enclosing)
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	}
+	return astprinter.Stringify(value)
+}
+
+// formatElement formats a value inside a list or map. Strings are quoted
+// there, so that ["a, b"] and ["a", "b"] print differently.
+func formatElement(value any, enclosing []any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return format(value, enclosing)
+}
+
+// sliceBound turns a slice bound into a position in a sequence of length
+// n, counting negative bounds from the end and clamping to [0, n].
+func sliceBound(bound, n int) int {
+	if bound < 0 {
+		bound += n
+	}
+	return max(0, min(bound, n))
+}
+
+// listNatives are the natives for working with lists. Apart from push and
+// pop they leave their arguments alone and return new lists, which are
+// charged against the memory limit like list literals.
+var listNatives = []struct {
+	name string
+	fn   any
+}{
+	{"len", func(value any) (int, error) {
+		switch v := value.(type) {
+		case string:
+			return utf8.RuneCountInString(v), nil
+		case *LoxList:
+			return len(v.elements), nil
+		case *LoxMap:
+			return len(v.keys), nil
+		}
+		return 0, fmt.Errorf("Argument 1 to 'len' must be a string, list or map, not %s.", typeName(value))
+	}},
+	{"push", func(i *Interpreter, list *LoxList, values ...any) error {
+		if err := i.allocate(ELEMENT_SIZE*len(values), i.callSpan); err != nil {
+			return err
+		}
+		list.elements = append(list.elements, values...)
+		return nil
+	}},
+	{"pop", func(list *LoxList) (any, error) {
+		if len(list.elements) == 0 {
+			return nil, errors.New("Can't pop from an empty list.")
+		}
+		last := list.elements[len(list.elements)-1]
+		list.elements = list.elements[:len(list.elements)-1]
+		return last, nil
+	}},
+	{"slice", func(i *Interpreter, list *LoxList, start int, end ...int) (*LoxList, error) {
+		if len(end) > 1 {
+			return nil, fmt.Errorf("Expected at most 3 arguments but got %d.", len(end)+2)
+		}
+		n := len(list.elements)
+		from, to := sliceBound(start, n), n
+		if len(end) == 1 {
+			to = sliceBound(end[0], n)
+		}
+		to = max(from, to)
+		if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*(to-from), i.callSpan); err != nil {
+			return nil, err
+		}
+		return NewLoxList(slices.Clone(list.elements[from:to])), nil
+	}},
+	{"map", func(i *Interpreter, list *LoxList, fn func(any) any) (*LoxList, error) {
+		if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*len(list.elements), i.callSpan); err != nil {
+			return nil, err
+		}
+		mapped := make([]any, len(list.elements))
+		for k, element := range list.elements {
+			mapped[k] = fn(element)
+		}
+		return NewLoxList(mapped), nil
+	}},
+	{"filter", func(i *Interpreter, list *LoxList, fn func(any) any) (*LoxList, error) {
+		var kept []any
+		for _, element := range list.elements {
+			if isTruthy(fn(element)) {
+				kept = append(kept, element)
+			}
+		}
+		if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*len(kept), i.callSpan); err != nil {
+			return nil, err
+		}
+		return NewLoxList(kept), nil
+	}},
+	{"sort", func(i *Interpreter, list *LoxList, compare ...func(a, b any) float64) (*LoxList, error) {
+		if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*len(list.elements), i.callSpan); err != nil {
+			return nil, err
+		}
+		sorted := slices.Clone(list.elements)
+		switch {
+		case len(compare) > 1:
+			return nil, fmt.Errorf("Expected at most 2 arguments but got %d.", len(compare)+1)
+		case len(compare) == 1:
+			slices.SortStableFunc(sorted, func(a, b any) int {
+				return cmp.Compare(compare[0](a, b), 0)
+			})
+		default:
+			if err := sortValues(sorted); err != nil {
+				return nil, err
+			}
+		}
+		return NewLoxList(sorted), nil
+	}},
+}
+
+var errUnsortable = errors.New("Can only sort a list of numbers or a list of strings without a comparison function.")
+
+// sortValues sorts a list of numbers or a list of strings in ascending
+// order.
+func sortValues(values []any) error {
+	switch {
+	case allOf[float64](values):
+		slices.SortStableFunc(values, func(a, b any) int { return cmp.Compare(a.(float64), b.(float64)) })
+	case allOf[string](values):
+		slices.SortStableFunc(values, func(a, b any) int { return cmp.Compare(a.(string), b.(string)) })
+	default:
+		return errUnsortable
+	}
+	return nil
+}
+
+func allOf[T any](values []any) bool {
+	for _, value := range values {
+		if _, ok := value.(T); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// LoxMap is a Lox map. Keys are strings, numbers, booleans or nil, and the
+// entries keep the order their keys were first added in.
+type LoxMap struct {
+	keys    []any
+	entries map[any]any
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{entries: make(map[any]any)}
+}
+
+// checkKey reports whether value can be used as a map key.
+func checkKey(value any) error {
+	switch v := value.(type) {
+	case nil, bool, string:
+		return nil
+	case float64:
+		if math.IsNaN(v) {
+			return errors.New("Map key can't be NaN.")
+		}
+		return nil
+	}
+	return fmt.Errorf("Map key must be a string, number, boolean or nil, not %s.", typeName(value))
+}
+
+func (m *LoxMap) get(key any) (any, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	value, ok := m.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("Key %s is not in the map.", quoted(key))
+	}
+	return value, nil
+}
+
+// has reports whether the map holds key, which checkKey has accepted.
+func (m *LoxMap) has(key any) bool {
+	_, ok := m.entries[key]
+	return ok
+}
+
+// set maps key, which checkKey has accepted, to value.
+func (m *LoxMap) set(key, value any) {
+	if !m.has(key) {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+}
+
+func (m *LoxMap) String() string {
+	return format(m, nil)
+}
+
+// quoted formats a map key for an error message, quoting strings so that
+// "1" and 1 read differently.
+func quoted(key any) string {
+	return formatElement(key, nil)
+}
+
+// mapNatives are the natives for working with maps. len is shared with
+// lists and strings and lives in listNatives.
+var mapNatives = []struct {
+	name string
+	fn   any
+}{
+	{"keys", func(i *Interpreter, m *LoxMap) (*LoxList, error) {
+		if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*len(m.keys), i.callSpan); err != nil {
+			return nil, err
+		}
+		return NewLoxList(slices.Clone(m.keys)), nil
+	}},
+	{"values", func(i *Interpreter, m *LoxMap) (*LoxList, error) {
+		if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*len(m.keys), i.callSpan); err != nil {
+			return nil, err
+		}
+		values := make([]any, len(m.keys))
+		for k, key := range m.keys {
+			values[k] = m.entries[key]
+		}
+		return NewLoxList(values), nil
+	}},
+	{"has", func(m *LoxMap, key any) (bool, error) {
+		if err := checkKey(key); err != nil {
+			return false, err
+		}
+		return m.has(key), nil
+	}},
+	{"remove", func(m *LoxMap, key any) (any, error) {
+		if err := checkKey(key); err != nil {
+			return nil, err
+		}
+		value := m.entries[key]
+		if m.has(key) {
+			delete(m.entries, key)
+			m.keys = slices.DeleteFunc(m.keys, func(k any) bool { return k == key })
+		}
+		return value, nil
+	}},
+}
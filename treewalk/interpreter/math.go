@@ -0,0 +1,76 @@
+package interpreter
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mathNatives are the natives for numbers. Those that take a number and
+// give one back follow IEEE 754, so sqrt(-1) is NaN rather than an error.
+var mathNatives = []struct {
+	name string
+	fn   any
+}{
+	{"floor", math.Floor},
+	{"ceil", math.Ceil},
+	{"round", math.Round},
+	{"abs", math.Abs},
+	{"sqrt", math.Sqrt},
+	{"pow", math.Pow},
+	{"min", func(first float64, rest ...float64) float64 {
+		for _, n := range rest {
+			first = math.Min(first, n)
+		}
+		return first
+	}},
+	{"max", func(first float64, rest ...float64) float64 {
+		for _, n := range rest {
+			first = math.Max(first, n)
+		}
+		return first
+	}},
+	{"sin", math.Sin},
+	{"cos", math.Cos},
+	{"tan", math.Tan},
+	{"asin", math.Asin},
+	{"acos", math.Acos},
+	{"atan", math.Atan},
+	{"atan2", math.Atan2},
+	{"exp", math.Exp},
+	{"log", math.Log},
+	{"log2", math.Log2},
+	{"log10", math.Log10},
+	{"isNaN", math.IsNaN},
+	{"isInf", func(n float64) bool {
+		return math.IsInf(n, 0)
+	}},
+	{"num", toNumber},
+	{"str", func(i *Interpreter, value any) (string, error) {
+		return allocated(i, format(value, nil))
+	}},
+}
+
+// numberPattern matches what num accepts: a Lox number literal with an
+// optional sign and exponent.
+var numberPattern = regexp.MustCompile(`^[+-]?\d+(\.\d+)?([eE][+-]?\d+)?$`)
+
+// toNumber implements num. Numbers come back unchanged and strings are
+// parsed, ignoring surrounding space; anything that is not a number gives
+// nil, so scripts can check their input.
+func toNumber(value any) any {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		s := strings.TrimSpace(v)
+		if !numberPattern.MatchString(s) {
+			return nil
+		}
+		// Out of range numbers parse as ±Inf, which is what we want.
+		n, _ := strconv.ParseFloat(s, 64)
+		return n
+	}
+	return nil
+}
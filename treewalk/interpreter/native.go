@@ -0,0 +1,502 @@
+package interpreter
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"lox/treewalk/astprinter"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// Native is a Lox callable implemented in Go. A variadic native takes at
+// least arity arguments.
+type Native struct {
+	name     string
+	minArity int
+	variadic bool
+	fn       func(interpreter *Interpreter, arguments []any) (any, error)
+}
+
+func NewNative(name string, arity int, variadic bool, fn func(interpreter *Interpreter, arguments []any) (any, error)) *Native {
+	return &Native{name, arity, variadic, fn}
+}
+
+// nativeError is an error returned by a native function. The call site turns
+// it into a runtime error, since only it knows where the call happened.
+type nativeError struct {
+	err error
+}
+
+func (e nativeError) Error() string {
+	return e.err.Error()
+}
+
+func (e nativeError) Unwrap() error {
+	return e.err
+}
+
+func (n *Native) arity() int {
+	return n.minArity
+}
+
+func (n *Native) call(interpreter *Interpreter, arguments []any) any {
+	value, err := n.fn(interpreter, arguments)
+	if err != nil {
+		return nativeError{err}
+	}
+	return value
+}
+
+func (n *Native) String() string {
+	if n.name == "" {
+		return "<native fn>"
+	}
+	return "<native fn " + n.name + ">"
+}
+
+// checkArity returns the message for calling function with got arguments,
+// or "" if that is allowed.
+func checkArity(function LoxCallable, got int) string {
+	want := function.arity()
+	if native, ok := function.(*Native); ok && native.variadic {
+		if got < want {
+			return fmt.Sprintf("Expected at least %d arguments but got %d.", want, got)
+		}
+		return ""
+	}
+	if got != want {
+		return fmt.Sprintf("Expected %d arguments but got %d.", want, got)
+	}
+	return ""
+}
+
+var (
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+	interpreterType = reflect.TypeOf((*Interpreter)(nil))
+)
+
+// WrapFunc makes a native function out of any Go function. Arguments are
+// converted from Lox values to the parameter types: numbers to any numeric
+// type (integers only if they have no fraction), strings, booleans, Lox
+// functions to Go function types, lists to slices element by element, and
+// anything to a parameter of type any.
+// Results go back through ToLox. A final error result, if non-nil, becomes a
+// Lox runtime error, and so does a panic in fn. A first parameter of type
+// *Interpreter is not an argument; it receives the interpreter making the
+// call.
+//
+// A Go function made from a Lox function argument may only be called while
+// the native runs, on its goroutine. If the Lox function fails, the native
+// fails with the same error, or the Go function returns it when its last
+// result is an error. Once the native has returned, the Go function no
+// longer runs Lox code: it returns zero values, with ErrCallbackExpired as
+// its error result if it has one.
+func WrapFunc(name string, fn any) (*Native, error) {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return nil, fmt.Errorf("native %q: %T is not a function", name, fn)
+	}
+	typ := value.Type()
+	if err := checkResults(typ); err != nil {
+		return nil, fmt.Errorf("native %q: %w", name, err)
+	}
+
+	// A leading *Interpreter parameter receives the running interpreter.
+	first := 0
+	if typ.NumIn() > 0 && typ.In(0) == interpreterType {
+		first = 1
+	}
+	arity := typ.NumIn() - first
+	if typ.IsVariadic() {
+		arity--
+	}
+
+	who, failed := "'"+name+"'", "Native function '"+name+"' failed"
+	if name == "" {
+		who, failed = "native function", "Native function failed"
+	}
+
+	return NewNative(name, arity, typ.IsVariadic(), func(interpreter *Interpreter, arguments []any) (result any, err error) {
+		call := &nativeCall{interpreter: interpreter}
+		defer func() {
+			call.done = true
+			if p := recover(); p != nil {
+				// A panicking native fails its call, not the whole run.
+				switch p := p.(type) {
+				case callbackError:
+					result, err = nil, p.err
+				case error:
+					result, err = nil, fmt.Errorf("%s: %w.", failed, p)
+				default:
+					result, err = nil, fmt.Errorf("%s: %v.", failed, p)
+				}
+			}
+		}()
+
+		in := make([]reflect.Value, first, first+len(arguments))
+		if first == 1 {
+			in[0] = reflect.ValueOf(interpreter)
+		}
+		for i, argument := range arguments {
+			var paramType reflect.Type
+			if typ.IsVariadic() && i >= arity {
+				paramType = typ.In(typ.NumIn() - 1).Elem()
+			} else {
+				paramType = typ.In(first + i)
+			}
+
+			v, err := fromLox(call, argument, paramType)
+			if err != nil {
+				return nil, fmt.Errorf("Argument %d to %s %v.", i+1, who, err)
+			}
+			in = append(in, v)
+		}
+		return results(value.Call(in))
+	}), nil
+}
+
+func checkResults(typ reflect.Type) error {
+	switch {
+	case typ.NumOut() > 2:
+		return errors.New("functions can return at most a value and an error")
+	case typ.NumOut() == 2 && typ.Out(1) != errorType:
+		return errors.New("the second result must be an error")
+	}
+	return nil
+}
+
+func results(out []reflect.Value) (any, error) {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return nil, err.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return ToLox(out[0].Interface())
+}
+
+// ToLox converts a Go value to the Lox value for it. Numbers become float64,
+// slices and arrays become lists, maps become Lox maps with their keys in
+// sorted order, and functions become natives; Lox values pass through
+// unchanged.
+func ToLox(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, float64, string, LoxCallable, *LoxInstance, *LoxError, *LoxList, *LoxMap:
+		return v, nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Func:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return WrapFunc("", value)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		elements := make([]any, v.Len())
+		for i := range elements {
+			element, err := ToLox(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return NewLoxList(elements), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return mapToLox(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot use %T as a Lox value", value)
+}
+
+func mapToLox(v reflect.Value) (*LoxMap, error) {
+	m := NewLoxMap()
+	for iter := v.MapRange(); iter.Next(); {
+		key, err := ToLox(iter.Key().Interface())
+		if err != nil {
+			return nil, err
+		}
+		if err := checkKey(key); err != nil {
+			return nil, fmt.Errorf("cannot use %v as a Lox map key", iter.Key())
+		}
+		value, err := ToLox(iter.Value().Interface())
+		if err != nil {
+			return nil, err
+		}
+		m.set(key, value)
+	}
+	slices.SortFunc(m.keys, compareKeys)
+	return m, nil
+}
+
+// compareKeys orders map keys: nil, then booleans, numbers and strings.
+func compareKeys(a, b any) int {
+	rank := func(key any) int {
+		switch key.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		}
+		return 3
+	}
+	if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+		return c
+	}
+	switch a := a.(type) {
+	case bool:
+		return cmp.Compare(boolRank(a), boolRank(b.(bool)))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return cmp.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// fromLox converts a Lox value to typ for call. Its errors complete the
+// sentence "Argument 1 to 'f' ...".
+func fromLox(call *nativeCall, value any, typ reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		switch typ.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Func, reflect.Map, reflect.Slice:
+			return reflect.Zero(typ), nil
+		}
+		return reflect.Value{}, fmt.Errorf("must be %s, not nil", describeType(typ))
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(typ) {
+		return v, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if n, ok := value.(float64); ok {
+			return reflect.ValueOf(n).Convert(typ), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := value.(float64); ok {
+			if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 || reflect.Zero(typ).OverflowInt(int64(n)) {
+				return reflect.Value{}, fmt.Errorf("must be an integer in range, not %s", astprinter.Stringify(n))
+			}
+			return reflect.ValueOf(int64(n)).Convert(typ), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := value.(float64); ok {
+			if n < 0 || n != math.Trunc(n) || n >= math.MaxUint64 || reflect.Zero(typ).OverflowUint(uint64(n)) {
+				return reflect.Value{}, fmt.Errorf("must be a non-negative integer in range, not %s", astprinter.Stringify(n))
+			}
+			return reflect.ValueOf(uint64(n)).Convert(typ), nil
+		}
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			return reflect.ValueOf(s).Convert(typ), nil
+		}
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			return reflect.ValueOf(b).Convert(typ), nil
+		}
+	case reflect.Func:
+		if callable, ok := value.(LoxCallable); ok {
+			if err := checkResults(typ); err != nil {
+				return reflect.Value{}, err
+			}
+			return makeFunc(call, callable, typ), nil
+		}
+	case reflect.Slice:
+		if list, ok := value.(*LoxList); ok {
+			slice := reflect.MakeSlice(typ, len(list.elements), len(list.elements))
+			for i, element := range list.elements {
+				v, err := fromLox(call, element, typ.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("must be %s, but element %d %v", describeType(typ), i, err)
+				}
+				slice.Index(i).Set(v)
+			}
+			return slice, nil
+		}
+	case reflect.Map:
+		if m, ok := value.(*LoxMap); ok {
+			result := reflect.MakeMapWithSize(typ, len(m.keys))
+			for _, key := range m.keys {
+				k, err := fromLox(call, key, typ.Key())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("must be %s, but key %s %v", describeType(typ), quoted(key), err)
+				}
+				v, err := fromLox(call, m.entries[key], typ.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("must be %s, but the value for %s %v", describeType(typ), quoted(key), err)
+				}
+				result.SetMapIndex(k, v)
+			}
+			return result, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("must be %s, not %s", describeType(typ), typeName(value))
+}
+
+// ErrCallbackExpired is returned by a Go function made from a Lox function
+// when it is called after the native it was passed to has returned.
+var ErrCallbackExpired = errors.New("Lox function called after its native returned")
+
+// nativeCall is one call of a native made by WrapFunc.
+type nativeCall struct {
+	interpreter *Interpreter
+	// done is set when the native returns; Go functions made for the call
+	// stop running Lox code then.
+	done bool
+}
+
+// callbackError carries an error out of a Go function made by makeFunc that
+// has no error result. The native that was given the function recovers it.
+type callbackError struct {
+	err error
+}
+
+// makeFunc wraps a Lox callable in a Go function of type typ. If the call
+// fails and typ has no error result, the Go function panics with a
+// callbackError, which only happens while the native is there to recover
+// it.
+func makeFunc(call *nativeCall, callable LoxCallable, typ reflect.Type) reflect.Value {
+	return reflect.MakeFunc(typ, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, typ.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(typ.Out(i))
+		}
+		if call.done {
+			if typ.NumOut() > 0 && typ.Out(typ.NumOut()-1) == errorType {
+				err := ErrCallbackExpired
+				out[typ.NumOut()-1] = reflect.ValueOf(&err).Elem()
+			}
+			return out
+		}
+
+		var arguments []any
+		for i, arg := range in {
+			if typ.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < arg.Len(); j++ {
+					arguments = append(arguments, arg.Index(j).Interface())
+				}
+				continue
+			}
+			arguments = append(arguments, arg.Interface())
+		}
+
+		value, err := callLox(call.interpreter, callable, arguments)
+		if err == nil && typ.NumOut() > 0 && typ.Out(0) != errorType {
+			var result reflect.Value
+			if result, err = fromLox(call, value, typ.Out(0)); err == nil {
+				out[0] = result
+			} else {
+				err = fmt.Errorf("Result of %v %v.", callable, err)
+			}
+		}
+
+		if err != nil {
+			if typ.NumOut() == 0 || typ.Out(typ.NumOut()-1) != errorType {
+				panic(callbackError{err})
+			}
+			out[typ.NumOut()-1] = reflect.ValueOf(&err).Elem()
+		}
+		return out
+	})
+}
+
+func callLox(interpreter *Interpreter, callable LoxCallable, arguments []any) (any, error) {
+	for i, argument := range arguments {
+		value, err := ToLox(argument)
+		if err != nil {
+			return nil, err
+		}
+		arguments[i] = value
+	}
+	return interpreter.callValue(callable, arguments)
+}
+
+func describeType(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "an integer"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Func:
+		return "a function"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map:
+		return "a map"
+	}
+	switch typ {
+	case reflect.TypeFor[*LoxList]():
+		return "a list"
+	case reflect.TypeFor[*LoxMap]():
+		return "a map"
+	}
+	return "a " + typ.String()
+}
+
+// typeName names the Lox type of value for error messages.
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case *LoxClass:
+		return "a class"
+	case LoxCallable:
+		return "a function"
+	case *LoxInstance:
+		return "an instance"
+	case *LoxError:
+		return "an error"
+	case *LoxList:
+		return "a list"
+	case *LoxMap:
+		return "a map"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,106 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+type Capability string
+
+const (
+	CAP_FS_READ  Capability = "fs.read"
+	CAP_FS_WRITE Capability = "fs.write"
+	CAP_ENV      Capability = "env"
+	CAP_CLOCK    Capability = "clock"
+	CAP_RANDOM   Capability = "random"
+	CAP_EXIT     Capability = "exit"
+)
+
+// ErrCapabilityDenied matches any CapabilityError with errors.Is.
+var ErrCapabilityDenied = errors.New("capability denied")
+
+// CapabilityError is behind the runtime error raised when a native needs a
+// capability the program was not granted.
+type CapabilityError struct {
+	Capability Capability
+	Native     string
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("Capability '%s' is required to call '%s'.", e.Capability, e.Native)
+}
+
+func (e *CapabilityError) Is(target error) bool {
+	return target == ErrCapabilityDenied
+}
+
+// Capabilities says which host resources the natives may touch. The zero
+// value grants nothing. If FSRoot is set, file paths are resolved inside
+// that directory and cannot escape it.
+type Capabilities struct {
+	FSRead  bool
+	FSWrite bool
+	FSRoot  string
+	Env     bool
+	Clock   bool
+	Random  bool
+	Exit    bool
+}
+
+// ALL_CAPABILITIES is what the command line interpreter runs with.
+var ALL_CAPABILITIES = Capabilities{FSRead: true, FSWrite: true, Env: true, Clock: true, Random: true, Exit: true}
+
+func (c Capabilities) allows(capability Capability) bool {
+	switch capability {
+	case CAP_FS_READ:
+		return c.FSRead
+	case CAP_FS_WRITE:
+		return c.FSWrite
+	case CAP_ENV:
+		return c.Env
+	case CAP_CLOCK:
+		return c.Clock
+	case CAP_RANDOM:
+		return c.Random
+	case CAP_EXIT:
+		return c.Exit
+	}
+	return false
+}
+
+func (i *Interpreter) SetCapabilities(capabilities Capabilities) {
+	i.capabilities = capabilities
+}
+
+// require fails unless the native named name may use capability.
+func (i *Interpreter) require(capability Capability, name string) error {
+	if i.capabilities.allows(capability) {
+		return nil
+	}
+	return &CapabilityError{capability, name}
+}
+
+// openFile opens path with flag, inside FSRoot if one is set.
+func (i *Interpreter) openFile(path string, flag int) (*os.File, error) {
+	if i.capabilities.FSRoot == "" {
+		return os.OpenFile(path, flag, 0644)
+	}
+
+	root, err := os.OpenRoot(i.capabilities.FSRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.OpenFile(path, flag, 0644)
+}
+
+// ExitError stops the program when it calls exit. It is not a runtime
+// error: the host decides what exiting means.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
@@ -0,0 +1,90 @@
+package interpreter
+
+import (
+	"io"
+	"lox/treewalk/env"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+)
+
+// defineStdlib adds the natives. Those that reach outside the interpreter
+// check their capability when called, not when defined, so programs can
+// still mention natives they are not allowed to use.
+func defineStdlib(globals *env.Environment) {
+	globals.Define("clock", Clock{})
+	globals.Define("Error", NewNative("Error", 1, false, newError))
+	globals.Define("PI", math.Pi)
+	globals.Define("E", math.E)
+
+	natives := []struct {
+		name       string
+		capability Capability
+		fn         any
+	}{
+		{"readFile", CAP_FS_READ, func(i *Interpreter, path string) (string, error) {
+			file, err := i.openFile(path, os.O_RDONLY)
+			if err != nil {
+				return "", err
+			}
+			defer file.Close()
+
+			data, err := io.ReadAll(file)
+			return string(data), err
+		}},
+		{"writeFile", CAP_FS_WRITE, func(i *Interpreter, path, contents string) error {
+			file, err := i.openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(file, contents); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		}},
+		{"getenv", CAP_ENV, func(i *Interpreter, name string) any {
+			if value, ok := os.LookupEnv(name); ok {
+				return value
+			}
+			return nil
+		}},
+		{"random", CAP_RANDOM, func(i *Interpreter) float64 {
+			return rand.Float64()
+		}},
+		{"exit", CAP_EXIT, func(i *Interpreter, code int) error {
+			return &ExitError{Code: code}
+		}},
+	}
+
+	for _, n := range natives {
+		globals.Define(n.name, guarded(n.name, n.capability, n.fn))
+	}
+
+	for _, n := range slices.Concat(listNatives, mapNatives, stringNatives, mathNatives) {
+		globals.Define(n.name, mustWrap(n.name, n.fn))
+	}
+}
+
+// mustWrap is WrapFunc for the natives defined here, which cannot fail.
+func mustWrap(name string, fn any) *Native {
+	native, err := WrapFunc(name, fn)
+	if err != nil {
+		panic(err)
+	}
+	return native
+}
+
+// guarded wraps fn as a native that needs capability.
+func guarded(name string, capability Capability, fn any) *Native {
+	native := mustWrap(name, fn)
+	inner := native.fn
+	native.fn = func(interpreter *Interpreter, arguments []any) (any, error) {
+		if err := interpreter.require(capability, name); err != nil {
+			return nil, err
+		}
+		return inner(interpreter, arguments)
+	}
+	return native
+}
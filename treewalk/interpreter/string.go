@@ -0,0 +1,194 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// MAX_STRING_LENGTH bounds the strings that repeat, join, replace and
+// format build. It holds with or without a memory limit, so that one call
+// can't exhaust the host's memory.
+const MAX_STRING_LENGTH = 1 << 24
+
+var errStringTooLong = fmt.Errorf("Strings can't be longer than %d bytes.", MAX_STRING_LENGTH)
+
+// stringNatives are the natives for working with strings. Positions and
+// lengths count characters, not bytes, and negative positions count back
+// from the end. The strings and lists they build are charged against the
+// memory limit.
+var stringNatives = []struct {
+	name string
+	fn   any
+}{
+	{"substr", func(i *Interpreter, s string, start int, length ...int) (string, error) {
+		if len(length) > 1 {
+			return "", fmt.Errorf("Expected at most 3 arguments but got %d.", len(length)+2)
+		}
+		runes := []rune(s)
+		from := sliceBound(start, len(runes))
+		to := len(runes)
+		if len(length) == 1 {
+			if length[0] < 0 {
+				return "", errors.New("Substring length can't be negative.")
+			}
+			to = from + min(length[0], len(runes)-from)
+		}
+		return allocated(i, string(runes[from:to]))
+	}},
+	{"indexOf", func(s, substr string) int {
+		i := strings.Index(s, substr)
+		if i < 0 {
+			return -1
+		}
+		return utf8.RuneCountInString(s[:i])
+	}},
+	{"contains", strings.Contains},
+	{"startsWith", strings.HasPrefix},
+	{"upper", func(i *Interpreter, s string) (string, error) {
+		return allocated(i, strings.ToUpper(s))
+	}},
+	{"lower", func(i *Interpreter, s string) (string, error) {
+		return allocated(i, strings.ToLower(s))
+	}},
+	{"trim", strings.TrimSpace},
+	{"split", func(i *Interpreter, s, sep string) ([]string, error) {
+		parts := strings.Split(s, sep)
+		// The parts share the bytes of s, so only the list is new.
+		if err := i.allocate(LIST_SIZE+ELEMENT_SIZE*len(parts), i.callSpan); err != nil {
+			return nil, err
+		}
+		return parts, nil
+	}},
+	{"join", func(i *Interpreter, elements []string, sep string) (string, error) {
+		length := len(sep) * max(len(elements)-1, 0)
+		for _, element := range elements {
+			length += len(element)
+		}
+		if length > MAX_STRING_LENGTH {
+			return "", errStringTooLong
+		}
+		return allocated(i, strings.Join(elements, sep))
+	}},
+	{"replace", func(i *Interpreter, s, old, new string) (string, error) {
+		matches := strings.Count(s, old)
+		if len(s)+matches*(len(new)-len(old)) > MAX_STRING_LENGTH {
+			return "", errStringTooLong
+		}
+		return allocated(i, strings.ReplaceAll(s, old, new))
+	}},
+	{"repeat", func(i *Interpreter, s string, count int) (string, error) {
+		if count < 0 {
+			return "", errors.New("Repeat count can't be negative.")
+		}
+		// Check before building the string, which could be huge.
+		if len(s) > 0 && count > MAX_STRING_LENGTH/len(s) {
+			return "", errStringTooLong
+		}
+		if err := i.allocate(count*len(s), i.callSpan); err != nil {
+			return "", err
+		}
+		return strings.Repeat(s, count), nil
+	}},
+	{"format", func(i *Interpreter, format string, args ...any) (string, error) {
+		s, err := formatString(format, args...)
+		if err != nil {
+			return "", err
+		}
+		return allocated(i, s)
+	}},
+}
+
+// allocated charges s, a string a native built, against the memory limit
+// and returns it.
+func allocated(i *Interpreter, s string) (string, error) {
+	if err := i.allocate(len(s), i.callSpan); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// formatString implements format. It understands the printf verbs %s and
+// %v for any value, %q for any value quoted, %d and %x for integers, %f, %e
+// and %g for numbers, and %% for a percent sign, each with optional flags,
+// width and precision.
+func formatString(format string, args ...any) (string, error) {
+	var b strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		end := i + 1
+		for end < len(format) && strings.IndexByte("+-# 0123456789.", format[end]) >= 0 {
+			end++
+		}
+		if end == len(format) {
+			return "", errors.New("Format string ends in the middle of a verb.")
+		}
+		spec, verb := format[i:end+1], format[end]
+		i = end
+
+		if verb == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return "", fmt.Errorf("Format verb %s has no argument.", spec)
+		}
+		arg := args[next]
+		next++
+
+		value, err := formatArgument(verb, arg)
+		if err != nil {
+			return "", fmt.Errorf("Format verb %s %v", spec, err)
+		}
+		// fmt caps each width and precision, so one verb can only go
+		// so far past the limit before it is caught.
+		fmt.Fprintf(&b, spec, value)
+		if b.Len() > MAX_STRING_LENGTH {
+			return "", errStringTooLong
+		}
+	}
+
+	if next < len(args) {
+		return "", fmt.Errorf("Format string uses %d of %d arguments.", next, len(args))
+	}
+	return b.String(), nil
+}
+
+// formatArgument checks arg against verb and returns the Go value to format
+// it with. Its errors complete the sentence "Format verb %d ...".
+func formatArgument(verb byte, arg any) (any, error) {
+	switch verb {
+	case 's', 'v', 'q':
+		return format(arg, nil), nil
+	case 'd', 'x', 'X':
+		n, ok := arg.(float64)
+		if !ok || n != math.Trunc(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("needs an integer, not %s.", describeValue(arg))
+		}
+		if n < math.MinInt64 || n >= math.MaxInt64 {
+			return nil, errors.New("needs an integer in range.")
+		}
+		return int64(n), nil
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		if n, ok := arg.(float64); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("needs a number, not %s.", typeName(arg))
+	}
+	return nil, errors.New("is not supported.")
+}
+
+// describeValue is typeName, except that a number is shown by its value.
+func describeValue(value any) string {
+	if _, ok := value.(float64); ok {
+		return format(value, nil)
+	}
+	return typeName(value)
+}
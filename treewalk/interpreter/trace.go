@@ -0,0 +1,51 @@
+package interpreter
+
+import "lox/treewalk/loxerrors"
+
+// The interpreter keeps a frame for each Lox function running, outermost
+// first, with a frame for top-level code at the bottom when running a
+// script. A frame's Line is where it last made a call, so while a call is
+// in progress the frames below it hold the call sites.
+
+func (i *Interpreter) pushFrame(function string) {
+	i.frames = append(i.frames, loxerrors.Frame{Function: function})
+}
+
+func (i *Interpreter) popFrame() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
+// callingFrom records that the innermost frame is making a call on line.
+func (i *Interpreter) callingFrom(line int) {
+	if n := len(i.frames); n > 0 {
+		i.frames[n-1].Line = line
+	}
+}
+
+// traced records the call stack on err if it is a runtime error that has
+// no trace yet. It must be called before the frame the error was raised
+// in is popped.
+func (i *Interpreter) traced(err error) error {
+	runtimeErr, ok := err.(*loxerrors.ErrorRuntime)
+	if !ok || runtimeErr.Trace() != nil || len(i.frames) == 0 {
+		return err
+	}
+
+	trace := make([]loxerrors.Frame, len(i.frames))
+	for k, frame := range i.frames {
+		trace[len(trace)-1-k] = frame
+	}
+	trace[0].Line = runtimeErr.Line()
+	runtimeErr.SetTrace(trace)
+	return err
+}
+
+// report reports err if it is a runtime error and returns it. Runtime
+// errors are reported when they stop the program rather than where they
+// are raised, so that each is reported once, with its trace.
+func (i *Interpreter) report(err error) error {
+	if runtimeErr, ok := err.(*loxerrors.ErrorRuntime); ok {
+		i.loxerror.RuntimeError(runtimeErr)
+	}
+	return err
+}
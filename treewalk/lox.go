@@ -2,29 +2,51 @@ package lox
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"lox/engine"
 	"lox/treewalk/astprinter"
 	"lox/treewalk/interpreter"
 	"lox/treewalk/loxerrors"
 	"lox/treewalk/parser"
+	"lox/treewalk/resolver"
 	"lox/treewalk/scanner"
 	"os"
 )
 
 const PROMPT = ">> "
 
+func init() {
+	engine.Register("treewalk", func() engine.Engine { return New() })
+}
+
 type lox struct {
-	printer  *astprinter.ASTPrinter
-	loxerror *loxerrors.LoxErrors
+	printer     *astprinter.ASTPrinter
+	loxerror    *loxerrors.LoxErrors
+	diagnostics loxerrors.Sink
+	stdout      io.Writer
+	stderr      io.Writer
 }
 
 func New() *lox {
-	return &lox{loxerror: loxerrors.New(), printer: astprinter.New()}
+	return &lox{loxerror: loxerrors.New(), printer: astprinter.New(), stdout: os.Stdout, stderr: os.Stderr}
+}
+
+// SetOutput sends program output to stdout and error reports to stderr.
+func (l *lox) SetOutput(stdout, stderr io.Writer) {
+	l.stdout = stdout
+	l.stderr = stderr
+}
+
+// SetDiagnostics sends diagnostics to sink instead of printing them to
+// stderr.
+func (l *lox) SetDiagnostics(sink loxerrors.Sink) {
+	l.diagnostics = sink
 }
 
 func (l *lox) RunPrompt(in io.Reader, out io.Writer) {
-	l.loxerror = loxerrors.New()
 	scanner := bufio.NewScanner(in)
 
 	for {
@@ -39,61 +61,78 @@ func (l *lox) RunPrompt(in io.Reader, out io.Writer) {
 			break
 		}
 
-		l.run(line)
-		l.loxerror.HadError = false
+		var exit *interpreter.ExitError
+		if err := l.run(line, out, true); errors.As(err, &exit) {
+			return
+		}
 	}
 }
 
-func (l lox) RunFile(filename string) {
-	l.loxerror = loxerrors.New()
-
+func (l *lox) RunFile(filename string) int {
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading %q: %v", filename, err)
-		os.Exit(1)
+		fmt.Fprintf(l.stderr, "Error reading %q: %v\n", filename, err)
+		return engine.EXIT_IO_ERROR
 	}
 
-	if len(data) > 0 {
-		l.run(string(data))
+	if len(data) == 0 {
+		fmt.Fprintln(l.stdout, "EOF null")
+		return engine.EXIT_OK
+	}
+	return l.RunSource(string(data))
+}
+
+func (l *lox) RunSource(source string) int {
+	var exit *interpreter.ExitError
+	if err := l.run(source, l.stdout, false); errors.As(err, &exit) {
+		return exit.Code
+	}
 
-	} else {
-		fmt.Println("EOF null")
+	if l.loxerror.HadError {
+		return engine.EXIT_DATA_ERROR
+	}
+	if l.loxerror.HadRuntimeError {
+		return engine.EXIT_SOFTWARE
 	}
+	return engine.EXIT_OK
 }
 
-func (l lox) run(source string) error {
+// run executes source with every capability granted, printing to out. If
+// echo is set, the value of a trailing expression statement is printed too,
+// as the prompt does. The error is only of interest for an
+// *interpreter.ExitError; others have been reported already.
+func (l *lox) run(source string, out io.Writer, echo bool) error {
+	sink := l.diagnostics
+	if sink == nil {
+		sink = loxerrors.NewTextSink(l.stderr, source)
+	}
+	l.loxerror = loxerrors.NewWithSink(sink)
+
 	scanner := scanner.New(source, l.loxerror)
 	tokens := scanner.ScanTokens()
 	parser := parser.New(tokens, l.loxerror)
-	interpreter := interpreter.New(l.loxerror)
-
-	// for _, token := range tokens {
-	// 	fmt.Println(token)
-	// }
+	interp := interpreter.New(l.loxerror)
+	interp.SetCapabilities(interpreter.ALL_CAPABILITIES)
+	interp.SetOutput(out)
 
 	statements, err := parser.Parse()
 	if err != nil {
 		return err
 	}
 
-	if l.loxerror.HadError {
-		os.Exit(65)
-	}
+	resolver := resolver.New(interp, l.loxerror)
+	resolver.Resolve(statements)
 
-	if l.loxerror.HadRuntimeError {
-		os.Exit(70)
+	if l.loxerror.HadError {
+		return nil
 	}
 
-	// l.printer.Print(exp)
-	fmt.Println(l.printer.PrintStmts(statements))
-	// interpreter.Interpret(statements)
-
-	value, err := interpreter.Interpret(statements)
+	value, err := interp.Interpret(context.Background(), statements)
 	if err != nil {
 		return err
 	}
-	if value != nil {
-		fmt.Println(astprinter.Stringify(value))
+	if echo && value != nil {
+		fmt.Fprintln(out, astprinter.Stringify(value))
 	}
 	return nil
 }
@@ -0,0 +1,151 @@
+package loxerrors
+
+import (
+	"fmt"
+	"io"
+	"lox/treewalk/token"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SEVERITY_ERROR Severity = iota
+	SEVERITY_WARNING
+)
+
+func (s Severity) String() string {
+	if s == SEVERITY_WARNING {
+		return "warning"
+	}
+	return "error"
+}
+
+// Code says which stage of the pipeline rejected the program.
+type Code string
+
+const (
+	CODE_SYNTAX  Code = "syntax"
+	CODE_RESOLVE Code = "resolve"
+	CODE_RUNTIME Code = "runtime"
+)
+
+// Diagnostic is a single problem found in a program. Line is the line the
+// book's message format reports, which is not always Span.Line: a runtime
+// error is reported on its operator but underlines the whole expression.
+// Span is zero when the location is only known by line.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Span     token.Span
+	Line     int
+	Message  string
+	Notes    []string
+
+	// where is the " at 'lexeme'" part of the message, if any.
+	where string
+}
+
+// HasSpan reports whether the diagnostic points at a range of source.
+func (d Diagnostic) HasSpan() bool {
+	return d.Span.Line != 0
+}
+
+// String formats the diagnostic the way the CLI prints it, without a
+// source snippet.
+func (d Diagnostic) String() string {
+	return d.Render("")
+}
+
+// Render formats the diagnostic as text. Compile errors use the book's
+// "[line N] Error at 'x': message" form. Runtime errors give the message
+// and then the notes, usually a stack trace, or just "[line N]" when there
+// are none. If source is given and the diagnostic has a span, the source
+// line follows with the span underlined.
+func (d Diagnostic) Render(source string) string {
+	var b strings.Builder
+	if d.Code == CODE_RUNTIME {
+		b.WriteString(d.Message + "\n")
+		if len(d.Notes) == 0 {
+			fmt.Fprintf(&b, "[line %d]\n", d.Line)
+		}
+	} else {
+		label := "Error"
+		if d.Severity == SEVERITY_WARNING {
+			label = "Warning"
+		}
+		fmt.Fprintf(&b, "[line %d] %s%s: %s\n", d.Line, label, d.where, d.Message)
+	}
+
+	for _, note := range d.Notes {
+		b.WriteString(note + "\n")
+	}
+
+	if source != "" && d.HasSpan() {
+		b.WriteString(Snippet(source, d.Span))
+	}
+	return b.String()
+}
+
+// Sink receives diagnostics as they are reported.
+type Sink interface {
+	Report(d Diagnostic)
+}
+
+// TextSink writes diagnostics to w, with snippets taken from source.
+type TextSink struct {
+	w      io.Writer
+	source string
+}
+
+func NewTextSink(w io.Writer, source string) *TextSink {
+	return &TextSink{w: w, source: source}
+}
+
+func (s *TextSink) Report(d Diagnostic) {
+	fmt.Fprint(s.w, d.Render(s.source))
+}
+
+// Collector keeps every diagnostic it is given, for callers that want to
+// inspect them rather than print them.
+type Collector struct {
+	Diagnostics []Diagnostic
+}
+
+func (c *Collector) Report(d Diagnostic) {
+	c.Diagnostics = append(c.Diagnostics, d)
+}
+
+// Snippet renders the source line containing the start of span with carets
+// under the spanned text. A span running past the end of the line is
+// underlined to the end of the line; an empty one gets a single caret.
+//
+//	3 | print a + "b";
+//	  |       ^^^^^^^
+func Snippet(source string, span token.Span) string {
+	start := min(max(span.Start, 0), len(source))
+	end := min(max(span.End, start), len(source))
+
+	lineStart := strings.LastIndexByte(source[:start], '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(source[start:], '\n'); i >= 0 {
+		lineEnd = start + i
+	}
+	end = min(end, lineEnd)
+	text := strings.TrimRight(source[lineStart:lineEnd], "\r")
+
+	// Keep tabs so the carets line up with the text above them.
+	var pad strings.Builder
+	for _, r := range source[lineStart:start] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+	width := max(len([]rune(source[start:end])), 1)
+
+	gutter := fmt.Sprintf("%4d", strings.Count(source[:lineStart], "\n")+1)
+	blank := strings.Repeat(" ", len(gutter))
+	return fmt.Sprintf("%s | %s\n%s | %s%s\n", gutter, text, blank, pad.String(), strings.Repeat("^", width))
+}
@@ -11,46 +11,149 @@ var (
 	ErrorParse = errors.New("ParseError")
 )
 
+// Frame is one call that was active when a runtime error happened: the
+// function running, empty for top-level code, and the line it had reached.
+type Frame struct {
+	Function string
+	Line     int
+}
+
+// String formats the frame as a line of a stack trace.
+func (f Frame) String() string {
+	name := "script"
+	if f.Function != "" {
+		name = f.Function + "()"
+	}
+	return fmt.Sprintf("[line %d] in %s", f.Line, name)
+}
+
 type ErrorRuntime struct {
-	token   token.Token
+	line    int
+	span    token.Span
 	message string
+	cause   error
+	trace   []Frame
 }
 
 func (r ErrorRuntime) Error() string {
 	return r.message
 }
 
+// Unwrap returns the Go error behind the runtime error, if there is one.
+func (r ErrorRuntime) Unwrap() error {
+	return r.cause
+}
+
+// Span is the source range the error is about.
+func (r ErrorRuntime) Span() token.Span {
+	return r.span
+}
+
+// Line is the line the error is reported on.
+func (r ErrorRuntime) Line() int {
+	return r.line
+}
+
+// Trace is the call stack when the error happened, innermost frame first.
+// It is nil until the interpreter records it.
+func (r ErrorRuntime) Trace() []Frame {
+	return r.trace
+}
+
 func NewErrorRuntime(token token.Token, message string) *ErrorRuntime {
-	return &ErrorRuntime{token: token, message: message}
+	return &ErrorRuntime{line: token.Line, span: token.Span(), message: message}
+}
+
+// NewErrorRuntimeAt reports the error on token's line but underlines span,
+// usually the whole expression that failed.
+func NewErrorRuntimeAt(token token.Token, span token.Span, message string) *ErrorRuntime {
+	return &ErrorRuntime{line: token.Line, span: span, message: message}
 }
 
+// NewErrorRuntimeCause reports a runtime error at span that hosts can match
+// against cause with errors.Is.
+func NewErrorRuntimeCause(span token.Span, message string, cause error) *ErrorRuntime {
+	return &ErrorRuntime{line: span.Line, span: span, message: message, cause: cause}
+}
+
+// Wrap records cause as the Go error behind r and returns r.
+func (r *ErrorRuntime) Wrap(cause error) *ErrorRuntime {
+	r.cause = cause
+	return r
+}
+
+// SetTrace records the call stack the error was raised in.
+func (r *ErrorRuntime) SetTrace(trace []Frame) {
+	r.trace = trace
+}
+
+// LoxErrors turns errors found while running a program into diagnostics
+// for its sink and remembers which kinds it has seen.
 type LoxErrors struct {
 	HadError        bool
 	HadRuntimeError bool
+	sink            Sink
 }
 
+// New reports to stderr, without source snippets.
 func New() *LoxErrors {
-	return &LoxErrors{}
+	return NewWithSink(NewTextSink(os.Stderr, ""))
+}
+
+func NewWithSink(sink Sink) *LoxErrors {
+	return &LoxErrors{sink: sink}
 }
 
+// Report hands d to the sink and records it in HadError or HadRuntimeError.
+func (le *LoxErrors) Report(d Diagnostic) {
+	if d.Severity == SEVERITY_ERROR {
+		if d.Code == CODE_RUNTIME {
+			le.HadRuntimeError = true
+		} else {
+			le.HadError = true
+		}
+	}
+	le.sink.Report(d)
+}
+
+// RuntimeError reports err with its stack trace, if it has one, as notes.
 func (le *LoxErrors) RuntimeError(err *ErrorRuntime) {
-	fmt.Fprintf(os.Stderr, err.message+"\n[line %d]\n", err.token.Line)
-	le.HadError = true
+	var notes []string
+	for _, frame := range err.trace {
+		notes = append(notes, frame.String())
+	}
+	le.Report(Diagnostic{
+		Code:    CODE_RUNTIME,
+		Span:    err.span,
+		Line:    err.line,
+		Message: err.message,
+		Notes:   notes,
+	})
 }
 
 func (le *LoxErrors) TokenError(tok token.Token, message string) {
+	le.tokenError(CODE_SYNTAX, tok, message)
+}
+
+// ResolveError reports a program that parses but breaks a static rule,
+// such as returning from top-level code.
+func (le *LoxErrors) ResolveError(tok token.Token, message string) {
+	le.tokenError(CODE_RESOLVE, tok, message)
+}
+
+func (le *LoxErrors) tokenError(code Code, tok token.Token, message string) {
+	where := " at '" + tok.Lexeme + "'"
 	if tok.Typ == token.EOF {
-		le.report(tok.Line, " at end", message)
-	} else {
-		le.report(tok.Line, " at '"+tok.Lexeme+"'", message)
+		where = " at end"
 	}
+	le.Report(Diagnostic{Code: code, Span: tok.Span(), Line: tok.Line, Message: message, where: where})
 }
 
 func (le *LoxErrors) Error(line int, message string) {
-	le.report(line, "", message)
+	le.Report(Diagnostic{Code: CODE_SYNTAX, Line: line, Message: message})
 }
 
-func (le *LoxErrors) report(line int, where string, message string) {
-	fmt.Fprintf(os.Stderr, "[line %d] Error%s: %s\n", line, where, message)
-	le.HadError = true
+// ErrorAt is Error with the source range to underline.
+func (le *LoxErrors) ErrorAt(line int, span token.Span, message string) {
+	le.Report(Diagnostic{Code: CODE_SYNTAX, Span: span, Line: line, Message: message})
 }
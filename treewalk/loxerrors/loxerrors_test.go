@@ -0,0 +1,50 @@
+package loxerrors
+
+import (
+	"lox/treewalk/token"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	source := "var a = 1;\n\tprint a - \"x\";\n"
+	span := token.Span{Start: 18, End: 25, Line: 2, Column: 8}
+
+	want := "   2 | \tprint a - \"x\";\n" +
+		"     | \t      ^^^^^^^\n"
+	if got := Snippet(source, span); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	collector := &Collector{}
+	le := NewWithSink(collector)
+
+	source := "print -nil;"
+	tok := token.NewAt(token.MINUS, "-", nil, 1, 6, 7)
+	le.ResolveError(tok, "Something static.")
+	le.RuntimeError(NewErrorRuntimeAt(tok, token.Span{Start: 6, End: 10, Line: 1, Column: 7}, "Operand must be a number."))
+
+	if !le.HadError || !le.HadRuntimeError {
+		t.Fatalf("HadError = %v, HadRuntimeError = %v, want both set", le.HadError, le.HadRuntimeError)
+	}
+	if len(collector.Diagnostics) != 2 {
+		t.Fatalf("collected %d diagnostics, want 2", len(collector.Diagnostics))
+	}
+
+	static, runtime := collector.Diagnostics[0], collector.Diagnostics[1]
+	if static.Code != CODE_RESOLVE || static.Severity != SEVERITY_ERROR || static.Line != 1 {
+		t.Errorf("unexpected static diagnostic %+v", static)
+	}
+	if got, want := static.String(), "[line 1] Error at '-': Something static.\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	want := "Operand must be a number.\n" +
+		"[line 1]\n" +
+		"   1 | print -nil;\n" +
+		"     |       ^^^^\n"
+	if got := runtime.Render(source); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -4,12 +4,25 @@ import (
 	"lox/treewalk/ast"
 	"lox/treewalk/loxerrors"
 	"lox/treewalk/token"
+	"strings"
 )
 
+// MAX_NESTING bounds how deeply statements and expressions may nest, so
+// that hostile input is rejected before it can exhaust the Go stack while
+// parsing, resolving or interpreting it. A chain of operators such as
+// 1 + 1 + 1 nests as deeply as it is long.
+const MAX_NESTING = 1000
+
 type Parser struct {
 	tokens   []token.Token
 	current  int
 	loxerror *loxerrors.LoxErrors
+	// loopDepth counts the loops around the current statement in the
+	// function being parsed.
+	loopDepth int
+	// depth counts the statements and expressions being parsed around the
+	// current token.
+	depth int
 }
 
 func New(tokens []token.Token, loxerror *loxerrors.LoxErrors) *Parser {
@@ -17,23 +30,31 @@ func New(tokens []token.Token, loxerror *loxerrors.LoxErrors) *Parser {
 }
 
 func (p *Parser) Parse() ([]ast.Stmt, error) {
-	var err error
+	var parseErr error
 
 	statements := []ast.Stmt{}
 	for !p.isAtEnd() {
 		statement, err := p.declaration()
 		if err != nil {
+			parseErr = err
 			p.synchronize()
+			continue
 		}
 		statements = append(statements, statement)
 	}
 
-	return statements, err
+	return statements, parseErr
 }
 
 func (p *Parser) declaration() (ast.Stmt, error) {
-	if p.match(token.FUN) {
-		return p.function("function")
+	if p.match(token.CLASS) {
+		return p.classDeclaration()
+	}
+
+	if p.check(token.FUN) && p.checkNext(token.IDENTIFIER) {
+		start := p.current
+		p.advance()
+		return p.function("function", start)
 	}
 
 	if p.match(token.VAR) {
@@ -43,7 +64,40 @@ func (p *Parser) declaration() (ast.Stmt, error) {
 	return p.statement()
 }
 
+func (p *Parser) classDeclaration() (ast.Stmt, error) {
+	start := p.current - 1
+	name, err := p.consume(token.IDENTIFIER, "Expect class name.")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(token.LEFT_BRACE, "Expect '{' before class body.")
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []*ast.Function
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		method, err := p.function("method", p.current)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method.(*ast.Function))
+	}
+
+	_, err = p.consume(token.RIGHT_BRACE, "Expect '}' after class body.")
+	if err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewClass(name, methods)), nil
+}
+
 func (p *Parser) statement() (ast.Stmt, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if err := p.checkDepth(); err != nil {
+		return nil, err
+	}
+
 	if p.match(token.FOR) {
 		return p.forStatement()
 	}
@@ -64,15 +118,32 @@ func (p *Parser) statement() (ast.Stmt, error) {
 		return p.whileStatement()
 	}
 
-	if p.match(token.LEFT_BRACE) {
+	if p.match(token.TRY) {
+		return p.tryStatement()
+	}
+
+	if p.match(token.THROW) {
+		return p.throwStatement()
+	}
+
+	if p.match(token.BREAK, token.CONTINUE) {
+		return p.loopJumpStatement()
+	}
+
+	if !p.startsMap() && p.match(token.LEFT_BRACE) {
+		start := p.current - 1
 		block, err := p.block()
-		return ast.NewBlock(block), err
+		if err != nil {
+			return nil, err
+		}
+		return spanned(p, start, ast.NewBlock(block)), nil
 	}
 
 	return p.expressionStatement()
 }
 
 func (p *Parser) varDeclaration() (ast.Stmt, error) {
+	start := p.current - 1
 	name, err := p.consume(token.IDENTIFIER, "Expect variable name.")
 	if err != nil {
 		return nil, err
@@ -86,13 +157,18 @@ func (p *Parser) varDeclaration() (ast.Stmt, error) {
 		}
 	}
 
-	p.consume(token.SEMICOLON, "Expect ';' after variable declaration.")
-	return ast.NewVar(name, initializer), nil
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after variable declaration."); err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewVar(name, initializer)), nil
 }
 
 func (p *Parser) forStatement() (ast.Stmt, error) {
 	var err error
-	p.consume(token.LEFT_PAREN, "Expect '(' after 'for'.")
+	start := p.current - 1
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'for'."); err != nil {
+		return nil, err
+	}
 
 	var initializer ast.Stmt
 	if p.match(token.SEMICOLON) {
@@ -116,7 +192,9 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	p.consume(token.SEMICOLON, "Expect ';' after loop condition.")
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after loop condition."); err != nil {
+		return nil, err
+	}
 
 	var increment ast.Expr
 	if !p.check(token.RIGHT_PAREN) {
@@ -125,39 +203,135 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	p.consume(token.RIGHT_PAREN, "Expect ')' after for clauses.")
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after for clauses."); err != nil {
+		return nil, err
+	}
 
-	body, err := p.statement()
+	body, err := p.loopBody()
 	if err != nil {
 		return nil, err
 	}
 
-	if increment != nil {
-		body = ast.NewBlock(append([]ast.Stmt{body}, ast.NewExpression(increment)))
-	}
+	// The desugared nodes all cover the whole for statement. The increment
+	// stays separate from the body so that continue still runs it.
 	if contidition == nil {
-		contidition = ast.NewLiteral(true)
+		contidition = spanned(p, start, ast.NewLiteral(true))
 	}
-	body = ast.NewWhile(contidition, body)
+	body = spanned(p, start, ast.NewWhile(contidition, body, increment))
 	if initializer != nil {
-		body = ast.NewBlock(append([]ast.Stmt{initializer}, body))
+		body = spanned(p, start, ast.NewBlock(append([]ast.Stmt{initializer}, body)))
 	}
 
 	return body, nil
 }
 
 func (p *Parser) whileStatement() (ast.Stmt, error) {
-	p.consume(token.LEFT_PAREN, "Expect '(' after 'while'.")
+	start := p.current - 1
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'while'."); err != nil {
+		return nil, err
+	}
 	condition, err := p.expression()
 	if err != nil {
 		return nil, err
 	}
-	p.consume(token.RIGHT_PAREN, "Expect ')' after condition.")
-	body, err := p.statement()
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after condition."); err != nil {
+		return nil, err
+	}
+	body, err := p.loopBody()
+	if err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewWhile(condition, body, nil)), nil
+}
+
+// loopBody parses the body of a loop, where break and continue are allowed.
+func (p *Parser) loopBody() (ast.Stmt, error) {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.statement()
+}
+
+// loopJumpStatement parses a break or continue statement. Both are errors
+// outside a loop, but the parser carries on.
+func (p *Parser) loopJumpStatement() (ast.Stmt, error) {
+	start := p.current - 1
+	keyword := p.previous()
+	if p.loopDepth == 0 {
+		p.loxerror.TokenError(keyword, "Can't use '"+keyword.Lexeme+"' outside of a loop.")
+	}
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after '"+keyword.Lexeme+"'."); err != nil {
+		return nil, err
+	}
+
+	if keyword.Typ == token.BREAK {
+		return spanned(p, start, ast.NewBreak(keyword)), nil
+	}
+	return spanned(p, start, ast.NewContinue(keyword)), nil
+}
+
+func (p *Parser) tryStatement() (ast.Stmt, error) {
+	start := p.current - 1
+	body, err := p.blockAfter("Expect '{' after 'try'.")
+	if err != nil {
+		return nil, err
+	}
+
+	var name token.Token
+	var catchBody *ast.Block
+	if p.match(token.CATCH) {
+		if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'catch'."); err != nil {
+			return nil, err
+		}
+		if name, err = p.consume(token.IDENTIFIER, "Expect error variable name."); err != nil {
+			return nil, err
+		}
+		if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after error variable."); err != nil {
+			return nil, err
+		}
+		if catchBody, err = p.blockAfter("Expect '{' after catch clause."); err != nil {
+			return nil, err
+		}
+	}
+
+	var finallyBody *ast.Block
+	if p.match(token.FINALLY) {
+		if finallyBody, err = p.blockAfter("Expect '{' after 'finally'."); err != nil {
+			return nil, err
+		}
+	}
+
+	if catchBody == nil && finallyBody == nil {
+		p.loxerror.TokenError(p.peek(), "Expect 'catch' or 'finally' after try block.")
+		return nil, loxerrors.ErrorParse
+	}
+	return spanned(p, start, ast.NewTry(body, name, catchBody, finallyBody)), nil
+}
+
+func (p *Parser) throwStatement() (ast.Stmt, error) {
+	start := p.current - 1
+	keyword := p.previous()
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after thrown value."); err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewThrow(keyword, value)), nil
+}
+
+// blockAfter parses a braced block that the grammar requires at this
+// point, failing with message if the '{' is missing.
+func (p *Parser) blockAfter(message string) (*ast.Block, error) {
+	start := p.current
+	if _, err := p.consume(token.LEFT_BRACE, message); err != nil {
+		return nil, err
+	}
+	statements, err := p.block()
 	if err != nil {
 		return nil, err
 	}
-	return ast.NewWhile(condition, body), nil
+	return spanned(p, start, ast.NewBlock(statements).(*ast.Block)), nil
 }
 
 func (p *Parser) block() ([]ast.Stmt, error) {
@@ -179,12 +353,17 @@ func (p *Parser) block() ([]ast.Stmt, error) {
 }
 
 func (p *Parser) ifStatement() (ast.Stmt, error) {
-	p.consume(token.LEFT_PAREN, "Expect '(' after 'if'.")
+	start := p.current - 1
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'if'."); err != nil {
+		return nil, err
+	}
 	condition, err := p.expression()
 	if err != nil {
 		return nil, err
 	}
-	p.consume(token.RIGHT_PAREN, "Expect ')' after if condition.")
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after if condition."); err != nil {
+		return nil, err
+	}
 
 	thenBranch, err := p.statement()
 	if err != nil {
@@ -197,20 +376,24 @@ func (p *Parser) ifStatement() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	return ast.NewIf(condition, thenBranch, elseBranch), nil
+	return spanned(p, start, ast.NewIf(condition, thenBranch, elseBranch)), nil
 }
 
 func (p *Parser) printStatement() (ast.Stmt, error) {
+	start := p.current - 1
 	value, err := p.expression()
 	if err != nil {
 		return nil, err
 	}
-	p.consume(token.SEMICOLON, "Expect ';' after value.")
-	return ast.NewPrint(value), nil
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after value."); err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewPrint(value)), nil
 }
 
 func (p *Parser) returnStatement() (ast.Stmt, error) {
 	var err error
+	start := p.current - 1
 	keyword := p.previous()
 	var value ast.Expr
 	if !p.check(token.SEMICOLON) {
@@ -220,25 +403,67 @@ func (p *Parser) returnStatement() (ast.Stmt, error) {
 		}
 	}
 
-	p.consume(token.SEMICOLON, "Expect ';' after return value.")
-	return ast.NewReturn(keyword, value), nil
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after return value."); err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewReturn(keyword, value)), nil
 }
 
 func (p *Parser) expressionStatement() (ast.Stmt, error) {
+	start := p.current
 	exp, err := p.expression()
 	if err != nil {
 		return nil, err
 	}
-	p.consume(token.SEMICOLON, "Expect ';' after expression.")
-	return ast.NewExpression(exp), nil
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after expression."); err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewExpression(exp)), nil
 }
 
-func (p *Parser) function(kind string) (ast.Stmt, error) {
-	name, err := p.consume(token.IDENTIFIER, "Expect "+kind+"name.")
+// function parses a named function or method. start is the index of its
+// first token, the 'fun' keyword for functions.
+func (p *Parser) function(kind string, start int) (ast.Stmt, error) {
+	name, err := p.consume(token.IDENTIFIER, "Expect "+kind+" name.")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after "+kind+" name."); err != nil {
+		return nil, err
+	}
+	parameters, body, err := p.functionBody(kind)
 	if err != nil {
 		return nil, err
 	}
-	p.consume(token.LEFT_PAREN, "Expect '(' after "+kind+" name.")
+	return spanned(p, start, ast.NewFunction(name, parameters, body)), nil
+}
+
+func (p *Parser) lambda() (ast.Expr, error) {
+	start := p.current - 1
+	keyword := p.previous()
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'fun'."); err != nil {
+		return nil, err
+	}
+	parameters, body, err := p.functionBody("function")
+	if err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewLambda(keyword, parameters, body)), nil
+}
+
+func (p *Parser) functionBody(kind string) ([]token.Token, []ast.Stmt, error) {
+	// Function declarations nest without going through statement.
+	p.depth++
+	defer func() { p.depth-- }()
+	if err := p.checkDepth(); err != nil {
+		return nil, nil, err
+	}
+
+	// Loops around the function do not count inside it.
+	enclosingLoops := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = enclosingLoops }()
+
 	var parameters []token.Token
 	if !p.check(token.RIGHT_PAREN) {
 		for {
@@ -248,7 +473,7 @@ func (p *Parser) function(kind string) (ast.Stmt, error) {
 
 			id, err := p.consume(token.IDENTIFIER, "Expect parameter name.")
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			parameters = append(parameters, id)
 
@@ -258,21 +483,51 @@ func (p *Parser) function(kind string) (ast.Stmt, error) {
 		}
 	}
 
-	p.consume(token.RIGHT_PAREN, "Expect ')' after parameters.")
-	p.consume(token.LEFT_BRACE, "Expect '{' before "+kind+" body.")
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after parameters."); err != nil {
+		return nil, nil, err
+	}
+	if _, err := p.consume(token.LEFT_BRACE, "Expect '{' before "+kind+" body."); err != nil {
+		return nil, nil, err
+	}
 
 	body, err := p.block()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return ast.NewFunction(name, parameters, body), nil
+	return parameters, body, nil
 }
 
 func (p *Parser) expression() (ast.Expr, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if err := p.checkDepth(); err != nil {
+		return nil, err
+	}
 	return p.assignment()
 }
 
+// checkDepth reports an error once nesting goes past MAX_NESTING.
+func (p *Parser) checkDepth() error {
+	if p.depth > MAX_NESTING {
+		p.loxerror.TokenError(p.peek(), "Nesting is too deep.")
+		return loxerrors.ErrorParse
+	}
+	return nil
+}
+
+// nest counts a level of nesting that the caller undoes with restoreDepth,
+// and checks it against MAX_NESTING.
+func (p *Parser) nest() error {
+	p.depth++
+	return p.checkDepth()
+}
+
+func (p *Parser) restoreDepth(depth int) {
+	p.depth = depth
+}
+
 func (p *Parser) assignment() (ast.Expr, error) {
+	start := p.current
 	exp, err := p.or()
 	if err != nil {
 		return nil, err
@@ -280,13 +535,26 @@ func (p *Parser) assignment() (ast.Expr, error) {
 
 	if p.match(token.EQUAL) {
 		equals := p.previous()
+		p.depth++
+		defer func() { p.depth-- }()
+		if err := p.checkDepth(); err != nil {
+			return nil, err
+		}
 		value, err := p.assignment()
 		if err != nil {
 			return nil, err
 		}
 
 		if variable, ok := exp.(*ast.Variable); ok {
-			return ast.NewAssign(variable.Name, value), nil
+			return spanned(p, start, ast.NewAssign(variable.Name, value)), nil
+		}
+
+		if get, ok := exp.(*ast.Get); ok {
+			return spanned(p, start, ast.NewSet(get.Object, get.Name, value)), nil
+		}
+
+		if index, ok := exp.(*ast.Index); ok {
+			return spanned(p, start, ast.NewSetIndex(index.Object, index.Bracket, index.Index, value)), nil
 		}
 
 		p.loxerror.TokenError(equals, "Invalid assignment target.")
@@ -296,133 +564,201 @@ func (p *Parser) assignment() (ast.Expr, error) {
 }
 
 func (p *Parser) or() (ast.Expr, error) {
+	// Each operator applied adds a level to the tree.
+	defer p.restoreDepth(p.depth)
+	start := p.current
 	exp, err := p.and()
 	if err != nil {
 		return nil, err
 	}
 	for p.match(token.OR) {
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		op := p.previous()
 		right, err := p.and()
 		if err != nil {
 			return nil, err
 		}
-		exp = ast.NewLogical(exp, op, right)
+		exp = spanned(p, start, ast.NewLogical(exp, op, right))
 	}
 
 	return exp, nil
 }
 
 func (p *Parser) and() (ast.Expr, error) {
+	// Each operator applied adds a level to the tree.
+	defer p.restoreDepth(p.depth)
+	start := p.current
 	exp, err := p.equality()
 	if err != nil {
 		return nil, err
 	}
 	for p.match(token.AND) {
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		op := p.previous()
 		right, err := p.equality()
 		if err != nil {
 			return nil, err
 		}
-		exp = ast.NewLogical(exp, op, right)
+		exp = spanned(p, start, ast.NewLogical(exp, op, right))
 	}
 
 	return exp, nil
 }
 
 func (p *Parser) equality() (ast.Expr, error) {
+	// Each operator applied adds a level to the tree.
+	defer p.restoreDepth(p.depth)
+	start := p.current
 	exp, err := p.comparision()
 	if err != nil {
 		return nil, err
 	}
 	for p.match(token.BANG_EQUAL, token.EQUAL_EQUAL) {
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		op := p.previous()
 		right, err := p.comparision()
 		if err != nil {
 			return nil, err
 		}
-		exp = ast.NewBinary(exp, op, right)
+		exp = spanned(p, start, ast.NewBinary(exp, op, right))
 	}
 
 	return exp, nil
 }
 
 func (p *Parser) comparision() (ast.Expr, error) {
+	// Each operator applied adds a level to the tree.
+	defer p.restoreDepth(p.depth)
+	start := p.current
 	exp, err := p.term()
 	if err != nil {
 		return nil, err
 	}
 
 	for p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		op := p.previous()
 		right, err := p.term()
 		if err != nil {
 			return nil, err
 		}
-		exp = ast.NewBinary(exp, op, right)
+		exp = spanned(p, start, ast.NewBinary(exp, op, right))
 	}
 
 	return exp, nil
 }
 
 func (p *Parser) term() (ast.Expr, error) {
+	// Each operator applied adds a level to the tree.
+	defer p.restoreDepth(p.depth)
+	start := p.current
 	exp, err := p.factor()
 	if err != nil {
 		return nil, err
 	}
 	for p.match(token.MINUS, token.PLUS) {
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		op := p.previous()
 		right, err := p.factor()
 		if err != nil {
 			return nil, err
 		}
-		exp = ast.NewBinary(exp, op, right)
+		exp = spanned(p, start, ast.NewBinary(exp, op, right))
 	}
 
 	return exp, nil
 }
 
 func (p *Parser) factor() (ast.Expr, error) {
+	// Each operator applied adds a level to the tree.
+	defer p.restoreDepth(p.depth)
+	start := p.current
 	exp, err := p.unary()
 	if err != nil {
 		return nil, err
 	}
 
 	for p.match(token.SLASH, token.STAR) {
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
 		op := p.previous()
 		right, err := p.unary()
 		if err != nil {
 			return nil, err
 		}
-		exp = ast.NewBinary(exp, op, right)
+		exp = spanned(p, start, ast.NewBinary(exp, op, right))
 	}
 	return exp, nil
 }
 
 func (p *Parser) unary() (ast.Expr, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if err := p.checkDepth(); err != nil {
+		return nil, err
+	}
+
+	start := p.current
 	if p.match(token.BANG, token.MINUS) {
 		op := p.previous()
 		right, err := p.unary()
 		if err != nil {
 			return nil, err
 		}
-		return ast.NewUnary(op, right), nil
+		return spanned(p, start, ast.NewUnary(op, right)), nil
 	}
 
 	return p.call()
 }
 
 func (p *Parser) call() (ast.Expr, error) {
+	// Each call, property access and index adds a level to the tree.
+	defer p.restoreDepth(p.depth)
+	start := p.current
 	exp, err := p.primary()
 	if err != nil {
 		return nil, err
 	}
 
 	for {
+		if p.check(token.LEFT_PAREN) || p.check(token.DOT) || p.check(token.LEFT_BRACKET) {
+			if err := p.nest(); err != nil {
+				return nil, err
+			}
+		}
 		if p.match(token.LEFT_PAREN) {
 			exp, err = p.finishCall(exp)
 			if err != nil {
 				return nil, err
 			}
+			exp = spanned(p, start, exp)
+		} else if p.match(token.DOT) {
+			name, err := p.consume(token.IDENTIFIER, "Expect property name after '.'.")
+			if err != nil {
+				return nil, err
+			}
+			exp = spanned(p, start, ast.NewGet(exp, name))
+		} else if p.match(token.LEFT_BRACKET) {
+			bracket := p.previous()
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after index."); err != nil {
+				return nil, err
+			}
+			exp = spanned(p, start, ast.NewIndex(exp, bracket, index))
 		} else {
 			break
 		}
@@ -455,19 +791,118 @@ func (p *Parser) finishCall(callee ast.Expr) (ast.Expr, error) {
 	return ast.NewCall(callee, paren, arguments), nil
 }
 
+func (p *Parser) list() (ast.Expr, error) {
+	start := p.current - 1
+	bracket := p.previous()
+	var elements []ast.Expr
+	if !p.check(token.RIGHT_BRACKET) {
+		for {
+			element, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after list elements."); err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewList(bracket, elements)), nil
+}
+
+// interpolation parses a string with interpolated expressions. The scanner
+// has split it into INTERPOLATION tokens, each followed by the tokens of an
+// expression, and a closing STRING token. The parts alternate between text
+// and expressions, starting and ending with text.
+func (p *Parser) interpolation() (ast.Expr, error) {
+	start := p.current - 1
+	parts := []ast.Expr{spanned(p, start, ast.NewLiteral(p.previous().Literal))}
+	for {
+		// The text after an interpolated expression starts at its '}'.
+		if p.check(token.STRING) || p.check(token.INTERPOLATION) {
+			if next := p.peek(); strings.HasPrefix(next.Lexeme, "}") {
+				p.loxerror.TokenError(next, "Expect expression.")
+				return nil, loxerrors.ErrorParse
+			}
+		}
+		exp, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, exp)
+
+		text := p.current
+		if p.match(token.INTERPOLATION) {
+			parts = append(parts, spanned(p, text, ast.NewLiteral(p.previous().Literal)))
+			continue
+		}
+		if _, err := p.consume(token.STRING, "Expect '}' after interpolated expression."); err != nil {
+			return nil, err
+		}
+		parts = append(parts, spanned(p, text, ast.NewLiteral(p.previous().Literal)))
+		return spanned(p, start, ast.NewInterpolation(parts)), nil
+	}
+}
+
+func (p *Parser) mapLiteral() (ast.Expr, error) {
+	start := p.current - 1
+	brace := p.previous()
+	var keys, values []ast.Expr
+	if !p.check(token.RIGHT_BRACE) {
+		for {
+			key, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.consume(token.COLON, "Expect ':' after map key."); err != nil {
+				return nil, err
+			}
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			values = append(values, value)
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(token.RIGHT_BRACE, "Expect '}' after map entries."); err != nil {
+		return nil, err
+	}
+	return spanned(p, start, ast.NewMap(brace, keys, values)), nil
+}
+
+// startsMap reports whether the '{' at the start of a statement opens a map
+// literal rather than a block. No statement starts with a single token and
+// a ':', so a map there is told apart by its first key being one token;
+// anything else, including "{}", is a block.
+func (p Parser) startsMap() bool {
+	return p.check(token.LEFT_BRACE) && p.current+2 < len(p.tokens) && p.tokens[p.current+2].Typ == token.COLON
+}
+
 func (p *Parser) primary() (ast.Expr, error) {
+	start := p.current
 	if p.match(token.FALSE) {
-		return ast.NewLiteral(false), nil
+		return spanned(p, start, ast.NewLiteral(false)), nil
 	}
 	if p.match(token.TRUE) {
-		return ast.NewLiteral(true), nil
+		return spanned(p, start, ast.NewLiteral(true)), nil
 	}
 	if p.match(token.NIL) {
-		return ast.NewLiteral(nil), nil
+		return spanned(p, start, ast.NewLiteral(nil)), nil
 	}
 
 	if p.match(token.NUMBER, token.STRING) {
-		return ast.NewLiteral(p.previous().Literal), nil
+		return spanned(p, start, ast.NewLiteral(p.previous().Literal)), nil
+	}
+
+	if p.match(token.INTERPOLATION) {
+		return p.interpolation()
 	}
 
 	if p.match(token.LEFT_PAREN) {
@@ -479,11 +914,27 @@ func (p *Parser) primary() (ast.Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		return ast.NewGrouping(exp), nil
+		return spanned(p, start, ast.NewGrouping(exp)), nil
+	}
+
+	if p.match(token.LEFT_BRACKET) {
+		return p.list()
+	}
+
+	if p.match(token.LEFT_BRACE) {
+		return p.mapLiteral()
+	}
+
+	if p.match(token.FUN) {
+		return p.lambda()
+	}
+
+	if p.match(token.THIS) {
+		return spanned(p, start, ast.NewThis(p.previous())), nil
 	}
 
 	if p.match(token.IDENTIFIER) {
-		return ast.NewVariable(p.previous()), nil
+		return spanned(p, start, ast.NewVariable(p.previous())), nil
 	}
 
 	p.loxerror.TokenError(p.peek(), "Expect expression.")
@@ -507,6 +958,13 @@ func (p Parser) check(typ token.TokenType) bool {
 	return p.peek().Typ == typ
 }
 
+func (p Parser) checkNext(typ token.TokenType) bool {
+	if p.isAtEnd() || p.tokens[p.current+1].Typ == token.EOF {
+		return false
+	}
+	return p.tokens[p.current+1].Typ == typ
+}
+
 func (p *Parser) advance() token.Token {
 	if !p.isAtEnd() {
 		p.current++
@@ -526,6 +984,17 @@ func (p Parser) previous() token.Token {
 	return p.tokens[p.current-1]
 }
 
+// span covers the tokens from index start through the last one consumed.
+func (p Parser) span(start int) token.Span {
+	return p.tokens[start].Span().To(p.previous().Span())
+}
+
+// spanned records on node the source range it was parsed from.
+func spanned[N ast.Node](p *Parser, start int, node N) N {
+	node.SetSpan(p.span(start))
+	return node
+}
+
 func (p *Parser) consume(typ token.TokenType, message string) (token.Token, error) {
 	if p.check(typ) {
 		return p.advance(), nil
@@ -547,6 +1016,8 @@ func (p *Parser) synchronize() {
 			return
 		case token.IF, token.WHILE, token.PRINT, token.RETURN:
 			return
+		case token.TRY, token.THROW:
+			return
 		}
 
 		p.advance()
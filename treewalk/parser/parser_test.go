@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"lox/treewalk/ast"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/scanner"
+	"strings"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	source := "print (a + 2) * foo.bar(1, \"b\");\n  x = -y;"
+	loxerror := loxerrors.New()
+	statements, err := New(scanner.New(source, loxerror).ScanTokens(), loxerror).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	print := statements[0].(*ast.Print)
+	product := print.Expression.(*ast.Binary)
+	call := product.Right.(*ast.Call)
+	assign := statements[1].(*ast.Expression).Expression.(*ast.Assign)
+
+	tests := []struct {
+		node   ast.Node
+		text   string
+		line   int
+		column int
+	}{
+		{print, `print (a + 2) * foo.bar(1, "b");`, 1, 1},
+		{product, `(a + 2) * foo.bar(1, "b")`, 1, 7},
+		{product.Left, "(a + 2)", 1, 7},
+		{product.Left.(*ast.Grouping).Expression, "a + 2", 1, 8},
+		{call, `foo.bar(1, "b")`, 1, 17},
+		{call.Callee, "foo.bar", 1, 17},
+		{call.Arguments[1], `"b"`, 1, 28},
+		{statements[1], "x = -y;", 2, 3},
+		{assign.Value, "-y", 2, 7},
+	}
+
+	for _, tt := range tests {
+		span := tt.node.Span()
+		if got := source[span.Start:span.End]; got != tt.text {
+			t.Errorf("span covers %q, want %q", got, tt.text)
+		}
+		if span.Line != tt.line || span.Column != tt.column {
+			t.Errorf("%q: at %d:%d, want %d:%d", tt.text, span.Line, span.Column, tt.line, tt.column)
+		}
+	}
+}
+
+func TestMapOrBlock(t *testing.T) {
+	source := `{"a": 1}; {x: 2}; { print 1; } {} var m = {};`
+	loxerror := loxerrors.New()
+	statements, err := New(scanner.New(source, loxerror).ScanTokens(), loxerror).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 5 {
+		t.Fatalf("got %d statements, want 5", len(statements))
+	}
+
+	for _, stmt := range statements[:2] {
+		if _, ok := stmt.(*ast.Expression).Expression.(*ast.Map); !ok {
+			t.Errorf("%T is not a map literal", stmt)
+		}
+	}
+	for _, stmt := range statements[2:4] {
+		if _, ok := stmt.(*ast.Block); !ok {
+			t.Errorf("%T is not a block", stmt)
+		}
+	}
+	if _, ok := statements[4].(*ast.Var).Initializer.(*ast.Map); !ok {
+		t.Error("initializer is not a map literal")
+	}
+}
+
+func TestUnicodeSpans(t *testing.T) {
+	source := "print \"é\\t\" + café;"
+	loxerror := loxerrors.New()
+	statements, err := New(scanner.New(source, loxerror).ScanTokens(), loxerror).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := statements[0].(*ast.Print).Expression.(*ast.Binary)
+	if got := sum.Left.(*ast.Literal).Value; got != "é\t" {
+		t.Errorf("string literal is %q, want %q", got, "é\t")
+	}
+	span := sum.Right.Span()
+	if got := source[span.Start:span.End]; got != "café" {
+		t.Errorf("span covers %q, want %q", got, "café")
+	}
+	if span.Column != 15 {
+		t.Errorf("café at column %d, want 15", span.Column)
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	source := `print "a${ {"k": "}"}["k"] }b${c}";`
+	loxerror := loxerrors.New()
+	statements, err := New(scanner.New(source, loxerror).ScanTokens(), loxerror).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interpolation := statements[0].(*ast.Print).Expression.(*ast.Interpolation)
+	if len(interpolation.Parts) != 5 {
+		t.Fatalf("got %d parts, want 5", len(interpolation.Parts))
+	}
+	for i, want := range []string{"a", "b", ""} {
+		if got := interpolation.Parts[2*i].(*ast.Literal).Value; got != want {
+			t.Errorf("text part %d is %q, want %q", i, got, want)
+		}
+	}
+	if _, ok := interpolation.Parts[1].(*ast.Index); !ok {
+		t.Errorf("part 1 is %T, want *ast.Index", interpolation.Parts[1])
+	}
+	if _, ok := interpolation.Parts[3].(*ast.Variable); !ok {
+		t.Errorf("part 3 is %T, want *ast.Variable", interpolation.Parts[3])
+	}
+}
+
+func TestLambda(t *testing.T) {
+	source := `fun named(a) { return a; }
+fun (a) { return a; };
+apply(fun (x) { return x; }, 1);
+(fun () {})();`
+	loxerror := loxerrors.New()
+	statements, err := New(scanner.New(source, loxerror).ScanTokens(), loxerror).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 4 {
+		t.Fatalf("got %d statements, want 4", len(statements))
+	}
+
+	if fn, ok := statements[0].(*ast.Function); !ok || fn.Name.Lexeme != "named" {
+		t.Errorf("statement 0 is %T, want a function declaration", statements[0])
+	}
+	lambda, ok := statements[1].(*ast.Expression).Expression.(*ast.Lambda)
+	if !ok || len(lambda.Params) != 1 || len(lambda.Body) != 1 {
+		t.Errorf("statement 1 is not a one-parameter lambda")
+	}
+	call := statements[2].(*ast.Expression).Expression.(*ast.Call)
+	if _, ok := call.Arguments[0].(*ast.Lambda); !ok {
+		t.Errorf("argument 0 is %T, want *ast.Lambda", call.Arguments[0])
+	}
+	immediate := statements[3].(*ast.Expression).Expression.(*ast.Call)
+	if _, ok := immediate.Callee.(*ast.Grouping).Expression.(*ast.Lambda); !ok {
+		t.Errorf("callee is not a grouped lambda")
+	}
+}
+
+func TestNestingLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"parentheses", "print " + strings.Repeat("(", 100000) + "1" + strings.Repeat(")", 100000) + ";"},
+		{"lists", "print " + strings.Repeat("[", 100000) + strings.Repeat("]", 100000) + ";"},
+		{"negation", "print " + strings.Repeat("-", 100000) + "1;"},
+		{"blocks", strings.Repeat("{", 100000) + strings.Repeat("}", 100000)},
+		{"if", strings.Repeat("if (true) ", 100000) + "print 1;"},
+		{"assignment", strings.Repeat("a = ", 100000) + "1;"},
+		{"or", "print 1" + strings.Repeat(" or 1", 100000) + ";"},
+		{"and", "print 1" + strings.Repeat(" and 1", 100000) + ";"},
+		{"equality", "print 1" + strings.Repeat(" == 1", 100000) + ";"},
+		{"comparison", "print 1" + strings.Repeat(" < 1", 100000) + ";"},
+		{"sum", "print 1" + strings.Repeat(" + 1", 100000) + ";"},
+		{"product", "print 1" + strings.Repeat(" * 1", 100000) + ";"},
+		{"calls", "f" + strings.Repeat("()", 100000) + ";"},
+		{"properties", "a" + strings.Repeat(".b", 100000) + ";"},
+		{"indices", "a" + strings.Repeat("[0]", 100000) + ";"},
+		{"functions", strings.Repeat("fun f() {", 100000) + strings.Repeat("}", 100000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &loxerrors.Collector{}
+			loxerror := loxerrors.NewWithSink(collector)
+			if _, err := New(scanner.New(tt.source, loxerror).ScanTokens(), loxerror).Parse(); err == nil {
+				t.Fatal("parsed without error")
+			}
+			if len(collector.Diagnostics) == 0 || collector.Diagnostics[0].Message != "Nesting is too deep." {
+				t.Errorf("got diagnostics %v, want %q", collector.Diagnostics, "Nesting is too deep.")
+			}
+		})
+	}
+
+	// Nesting below the limit still parses.
+	for _, source := range []string{
+		"print " + strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100) + ";",
+		"print 1" + strings.Repeat(" + 1", 100) + ";",
+		strings.Repeat("a = ", 100) + "1;",
+	} {
+		loxerror := loxerrors.New()
+		if _, err := New(scanner.New(source, loxerror).ScanTokens(), loxerror).Parse(); err != nil {
+			t.Errorf("%.20s...: %v", source, err)
+		}
+	}
+}
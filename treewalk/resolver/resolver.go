@@ -0,0 +1,330 @@
+package resolver
+
+import (
+	"lox/treewalk/ast"
+	"lox/treewalk/interpreter"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/token"
+)
+
+type FunctionType int
+
+const (
+	NONE FunctionType = iota
+	FUNCTION
+	INITIALIZER
+	METHOD
+)
+
+type ClassType int
+
+const (
+	NO_CLASS ClassType = iota
+	CLASS
+)
+
+type Resolver struct {
+	interpreter     *interpreter.Interpreter
+	loxerror        *loxerrors.LoxErrors
+	scopes          []map[string]bool
+	currentFunction FunctionType
+	currentClass    ClassType
+}
+
+func New(interpreter *interpreter.Interpreter, loxerror *loxerrors.LoxErrors) *Resolver {
+	return &Resolver{interpreter: interpreter, loxerror: loxerror}
+}
+
+func (r *Resolver) Resolve(statements []ast.Stmt) {
+	for _, statement := range statements {
+		r.resolveStmt(statement)
+	}
+}
+
+func (r *Resolver) resolveStmt(stmt ast.Stmt) {
+	stmt.Accept(r)
+}
+
+func (r *Resolver) resolveExpr(exp ast.Expr) {
+	exp.Accept(r)
+}
+
+func (r *Resolver) resolveFunction(params []token.Token, body []ast.Stmt, typ FunctionType) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = typ
+
+	r.beginScope()
+	for _, param := range params {
+		r.declare(param)
+		r.define(param)
+	}
+	r.Resolve(body)
+	r.endScope()
+
+	r.currentFunction = enclosingFunction
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]bool))
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *Resolver) declare(name token.Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+
+	scope := r.scopes[len(r.scopes)-1]
+	if _, ok := scope[name.Lexeme]; ok {
+		r.loxerror.ResolveError(name, "Already a variable with this name in this scope.")
+	}
+	scope[name.Lexeme] = false
+}
+
+func (r *Resolver) define(name token.Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.scopes[len(r.scopes)-1][name.Lexeme] = true
+}
+
+func (r *Resolver) resolveLocal(exp ast.Expr, name token.Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if _, ok := r.scopes[i][name.Lexeme]; ok {
+			r.interpreter.Resolve(exp, len(r.scopes)-1-i)
+			return
+		}
+	}
+}
+
+func (r *Resolver) VisitBlockStmt(stmt *ast.Block) any {
+	r.beginScope()
+	r.Resolve(stmt.Statements)
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitClassStmt(stmt *ast.Class) any {
+	enclosingClass := r.currentClass
+	r.currentClass = CLASS
+
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = true
+
+	for _, method := range stmt.Methods {
+		declaration := METHOD
+		if method.Name.Lexeme == "init" {
+			declaration = INITIALIZER
+		}
+		r.resolveFunction(method.Params, method.Body, declaration)
+	}
+
+	r.endScope()
+	r.currentClass = enclosingClass
+	return nil
+}
+
+func (r *Resolver) VisitVarStmt(stmt *ast.Var) any {
+	r.declare(stmt.Name)
+	if stmt.Initializer != nil {
+		r.resolveExpr(stmt.Initializer)
+	}
+	r.define(stmt.Name)
+	return nil
+}
+
+func (r *Resolver) VisitFunctionStmt(stmt *ast.Function) any {
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	r.resolveFunction(stmt.Params, stmt.Body, FUNCTION)
+	return nil
+}
+
+func (r *Resolver) VisitExpressionStmt(stmt *ast.Expression) any {
+	r.resolveExpr(stmt.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitIfStmt(stmt *ast.If) any {
+	r.resolveExpr(stmt.Condition)
+	r.resolveStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		r.resolveStmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitPrintStmt(stmt *ast.Print) any {
+	r.resolveExpr(stmt.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitReturnStmt(stmt *ast.Return) any {
+	if r.currentFunction == NONE {
+		r.loxerror.ResolveError(stmt.Keyword, "Can't return from top-level code.")
+	}
+
+	if stmt.Value != nil {
+		if r.currentFunction == INITIALIZER {
+			r.loxerror.ResolveError(stmt.Keyword, "Can't return a value from an initializer.")
+		}
+		r.resolveExpr(stmt.Value)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitWhileStmt(stmt *ast.While) any {
+	r.resolveExpr(stmt.Condition)
+	r.resolveStmt(stmt.Body)
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitBreakStmt(stmt *ast.Break) any {
+	return nil
+}
+
+func (r *Resolver) VisitContinueStmt(stmt *ast.Continue) any {
+	return nil
+}
+
+func (r *Resolver) VisitThrowStmt(stmt *ast.Throw) any {
+	r.resolveExpr(stmt.Value)
+	return nil
+}
+
+func (r *Resolver) VisitTryStmt(stmt *ast.Try) any {
+	r.resolveStmt(stmt.Body)
+	if stmt.CatchBody != nil {
+		r.beginScope()
+		r.declare(stmt.CatchName)
+		r.define(stmt.CatchName)
+		r.Resolve(stmt.CatchBody.Statements)
+		r.endScope()
+	}
+	if stmt.FinallyBody != nil {
+		r.resolveStmt(stmt.FinallyBody)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitListExpr(exp *ast.List) any {
+	for _, element := range exp.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitMapExpr(exp *ast.Map) any {
+	for k, key := range exp.Keys {
+		r.resolveExpr(key)
+		r.resolveExpr(exp.Values[k])
+	}
+	return nil
+}
+
+func (r *Resolver) VisitInterpolationExpr(exp *ast.Interpolation) any {
+	for _, part := range exp.Parts {
+		r.resolveExpr(part)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitIndexExpr(exp *ast.Index) any {
+	r.resolveExpr(exp.Object)
+	r.resolveExpr(exp.Index)
+	return nil
+}
+
+func (r *Resolver) VisitSetIndexExpr(exp *ast.SetIndex) any {
+	r.resolveExpr(exp.Value)
+	r.resolveExpr(exp.Object)
+	r.resolveExpr(exp.Index)
+	return nil
+}
+
+func (r *Resolver) VisitVariableExpr(exp *ast.Variable) any {
+	if len(r.scopes) > 0 {
+		if defined, ok := r.scopes[len(r.scopes)-1][exp.Name.Lexeme]; ok && !defined {
+			r.loxerror.ResolveError(exp.Name, "Can't read local variable in its own initializer.")
+		}
+	}
+
+	r.resolveLocal(exp, exp.Name)
+	return nil
+}
+
+func (r *Resolver) VisitAssignExpr(exp *ast.Assign) any {
+	r.resolveExpr(exp.Value)
+	r.resolveLocal(exp, exp.Name)
+	return nil
+}
+
+func (r *Resolver) VisitBinaryExpr(exp *ast.Binary) any {
+	r.resolveExpr(exp.Left)
+	r.resolveExpr(exp.Right)
+	return nil
+}
+
+func (r *Resolver) VisitCallExpr(exp *ast.Call) any {
+	r.resolveExpr(exp.Callee)
+	for _, argument := range exp.Arguments {
+		r.resolveExpr(argument)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitGetExpr(exp *ast.Get) any {
+	r.resolveExpr(exp.Object)
+	return nil
+}
+
+func (r *Resolver) VisitSetExpr(exp *ast.Set) any {
+	r.resolveExpr(exp.Value)
+	r.resolveExpr(exp.Object)
+	return nil
+}
+
+func (r *Resolver) VisitThisExpr(exp *ast.This) any {
+	if r.currentClass == NO_CLASS {
+		r.loxerror.ResolveError(exp.Keyword, "Can't use 'this' outside of a class.")
+		return nil
+	}
+
+	r.resolveLocal(exp, exp.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitLambdaExpr(exp *ast.Lambda) any {
+	r.resolveFunction(exp.Params, exp.Body, FUNCTION)
+	return nil
+}
+
+func (r *Resolver) VisitGroupingExpr(exp *ast.Grouping) any {
+	r.resolveExpr(exp.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitLiteralExpr(exp *ast.Literal) any {
+	return nil
+}
+
+func (r *Resolver) VisitLogicalExpr(exp *ast.Logical) any {
+	r.resolveExpr(exp.Left)
+	r.resolveExpr(exp.Right)
+	return nil
+}
+
+func (r *Resolver) VisitUnaryExpr(exp *ast.Unary) any {
+	r.resolveExpr(exp.Right)
+	return nil
+}
@@ -0,0 +1,51 @@
+package resolver
+
+import (
+	"lox/treewalk/interpreter"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/parser"
+	"lox/treewalk/scanner"
+	"testing"
+)
+
+func resolve(source string) *loxerrors.LoxErrors {
+	loxerror := loxerrors.New()
+	tokens := scanner.New(source, loxerror).ScanTokens()
+	statements, _ := parser.New(tokens, loxerror).Parse()
+	New(interpreter.New(loxerror), loxerror).Resolve(statements)
+	return loxerror
+}
+
+func TestStaticErrors(t *testing.T) {
+	tests := []string{
+		"{ var a = a; }",
+		"var a = 1; { var a = a; }",
+		"{ var a; var a; }",
+		"fun f(a, a) {}",
+		"return 1;",
+		"print this;",
+		"fun f() { this; }",
+		"class A { init() { return 1; } }",
+	}
+
+	for _, source := range tests {
+		if !resolve(source).HadError {
+			t.Errorf("%q: expected a static error", source)
+		}
+	}
+}
+
+func TestValidPrograms(t *testing.T) {
+	tests := []string{
+		"var a = 1; var a = 2;",
+		"fun f() { return 1; }",
+		"class A { init() { return; } get() { return this; } }",
+		"{ var a = 1; { var a = 2; } }",
+	}
+
+	for _, source := range tests {
+		if resolve(source).HadError {
+			t.Errorf("%q: unexpected static error", source)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package lox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"lox/treewalk/interpreter"
+	"lox/treewalk/loxerrors"
+	"lox/treewalk/parser"
+	"lox/treewalk/resolver"
+	"lox/treewalk/scanner"
+	"reflect"
+	"strings"
+)
+
+// Value is a Lox value as seen from Go: nil, bool, float64 or string, or an
+// opaque function, class or instance that can be passed back into Lox.
+type Value = any
+
+// CompileError is returned when source does not scan, parse or resolve.
+type CompileError struct {
+	Diagnostics []loxerrors.Diagnostic
+}
+
+func (e *CompileError) Error() string {
+	messages := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		messages[i] = strings.TrimSuffix(d.String(), "\n")
+	}
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError is returned when a program fails while running. Frames is
+// the Lox call stack at the time, innermost first. It unwraps to the cause
+// of a limit being hit, such as interpreter.ErrStepLimit or
+// context.DeadlineExceeded.
+type RuntimeError struct {
+	Diagnostic loxerrors.Diagnostic
+	Frames     []loxerrors.Frame
+	err        error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Diagnostic.Message
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.err
+}
+
+// Runtime runs Lox code inside a Go program. Globals defined by one call to
+// Eval are visible to the next. A Runtime is not safe for concurrent use.
+type Runtime struct {
+	interpreter *interpreter.Interpreter
+	loxerror    *loxerrors.LoxErrors
+	collector   *loxerrors.Collector
+}
+
+func NewRuntime() *Runtime {
+	collector := &loxerrors.Collector{}
+	loxerror := loxerrors.NewWithSink(collector)
+	return &Runtime{interpreter: interpreter.New(loxerror), loxerror: loxerror, collector: collector}
+}
+
+// Eval runs source and returns the value of its last statement if that is
+// an expression statement, or nil. A program that calls exit stops with an
+// *interpreter.ExitError.
+func (r *Runtime) Eval(ctx context.Context, source string) (value Value, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.reset()
+	defer r.recover(&err)
+
+	tokens := scanner.New(source, r.loxerror).ScanTokens()
+	statements, _ := parser.New(tokens, r.loxerror).Parse()
+	if !r.loxerror.HadError {
+		resolver.New(r.interpreter, r.loxerror).Resolve(statements)
+	}
+	if r.loxerror.HadError {
+		return nil, &CompileError{Diagnostics: r.collector.Diagnostics}
+	}
+
+	value, err = r.interpreter.Interpret(ctx, statements)
+	if err != nil {
+		return nil, r.runtimeError(err)
+	}
+	return value, nil
+}
+
+// SetGlobal defines a global variable, converting value as
+// interpreter.ToLox does. Go functions are registered as natives.
+func (r *Runtime) SetGlobal(name string, value any) error {
+	if reflect.ValueOf(value).Kind() == reflect.Func {
+		return r.RegisterNative(name, value)
+	}
+
+	v, err := interpreter.ToLox(value)
+	if err != nil {
+		return fmt.Errorf("global %q: %w", name, err)
+	}
+	r.interpreter.DefineGlobal(name, v)
+	return nil
+}
+
+// SetLimits bounds the resources each later Eval or Call may use.
+func (r *Runtime) SetLimits(limits interpreter.Limits) {
+	r.interpreter.SetLimits(limits)
+}
+
+// SetCapabilities decides which host resources later programs may use. A
+// new Runtime has none.
+func (r *Runtime) SetCapabilities(capabilities interpreter.Capabilities) {
+	r.interpreter.SetCapabilities(capabilities)
+}
+
+// SetOutput sends the output of print statements to w instead of
+// os.Stdout. Errors are never printed; they are returned.
+func (r *Runtime) SetOutput(w io.Writer) {
+	r.interpreter.SetOutput(w)
+}
+
+// GetGlobal returns the value of a global variable.
+func (r *Runtime) GetGlobal(name string) (Value, bool) {
+	return r.interpreter.Global(name)
+}
+
+// Call calls the global function or class fnName with args, converted as
+// by SetGlobal.
+func (r *Runtime) Call(fnName string, args ...any) (Value, error) {
+	return r.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is Call that stops when ctx is done.
+func (r *Runtime) CallContext(ctx context.Context, fnName string, args ...any) (value Value, err error) {
+	callee, ok := r.interpreter.Global(fnName)
+	if !ok {
+		return nil, fmt.Errorf("undefined function %q", fnName)
+	}
+
+	arguments := make([]any, len(args))
+	for i, arg := range args {
+		if arguments[i], err = interpreter.ToLox(arg); err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+	}
+
+	r.reset()
+	defer r.recover(&err)
+
+	value, err = r.interpreter.Call(ctx, callee, arguments)
+	if err != nil {
+		return nil, r.runtimeError(err)
+	}
+	return value, nil
+}
+
+// RegisterNative makes the Go function fn callable from Lox as name. See
+// interpreter.WrapFunc for how arguments and results are converted. A
+// non-nil error result becomes a Lox runtime error.
+func (r *Runtime) RegisterNative(name string, fn any) error {
+	native, err := interpreter.WrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	r.interpreter.DefineGlobal(name, native)
+	return nil
+}
+
+func (r *Runtime) reset() {
+	r.loxerror.HadError = false
+	r.loxerror.HadRuntimeError = false
+	r.collector.Diagnostics = nil
+}
+
+// recover turns a panic escaping the interpreter into an error, so a bug in
+// Lox code or in the interpreter cannot take the host process down.
+func (r *Runtime) recover(err *error) {
+	if p := recover(); p != nil {
+		*err = fmt.Errorf("lox: internal error: %v", p)
+	}
+}
+
+// runtimeError pairs err with the diagnostic reported for it, if any.
+func (r *Runtime) runtimeError(err error) error {
+	var frames []loxerrors.Frame
+	var runtimeErr *loxerrors.ErrorRuntime
+	if errors.As(err, &runtimeErr) {
+		frames = runtimeErr.Trace()
+	}
+
+	for _, d := range r.collector.Diagnostics {
+		if d.Code == loxerrors.CODE_RUNTIME {
+			return &RuntimeError{Diagnostic: d, Frames: frames, err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,473 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"lox/treewalk/interpreter"
+	"lox/treewalk/loxerrors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuntime(t *testing.T) {
+	ctx := context.Background()
+	r := NewRuntime()
+
+	if err := r.SetGlobal("limit", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Eval(ctx, "fun scale(x) { return x * limit; } var total = scale(2);"); err != nil {
+		t.Fatal(err)
+	}
+
+	if total, ok := r.GetGlobal("total"); !ok || total != 6.0 {
+		t.Errorf("total = %v, %v; want 6", total, ok)
+	}
+
+	value, err := r.Eval(ctx, "scale(total) + 1;")
+	if err != nil || value != 19.0 {
+		t.Errorf("Eval = %v, %v; want 19", value, err)
+	}
+
+	value, err = r.Call("scale", 5)
+	if err != nil || value != 15.0 {
+		t.Errorf("Call = %v, %v; want 15", value, err)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	ctx := context.Background()
+	r := NewRuntime()
+
+	_, err := r.Eval(ctx, "print 1 +;\nvar 1;")
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) || len(compileErr.Diagnostics) != 2 {
+		t.Fatalf("got %v, want a CompileError with two diagnostics", err)
+	}
+
+	_, err = r.Eval(ctx, "var a = 1;\nprint a - \"b\";")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("got %v, want a RuntimeError", err)
+	}
+	if runtimeErr.Diagnostic.Line != 2 || runtimeErr.Error() != "Operands must be numbers." {
+		t.Errorf("unexpected runtime error %+v", runtimeErr.Diagnostic)
+	}
+
+	// The runtime is still usable after errors.
+	if value, err := r.Eval(ctx, "a;"); err != nil || value != 1.0 {
+		t.Errorf("Eval = %v, %v; want 1", value, err)
+	}
+
+	if _, err := r.Call("missing"); err == nil {
+		t.Error("Call of an undefined function succeeded")
+	}
+	if _, err := r.Call("a"); err == nil {
+		t.Error("Call of a number succeeded")
+	}
+	if err := r.SetGlobal("c", make(chan int)); err == nil {
+		t.Error("SetGlobal accepted a channel")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := r.Eval(canceled, "1;"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestRegisterNative(t *testing.T) {
+	ctx := context.Background()
+	r := NewRuntime()
+
+	natives := map[string]any{
+		"sum": func(xs ...float64) float64 {
+			total := 0.0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		},
+		"repeat": func(s string, n int) string { return strings.Repeat(s, n) },
+		"half": func(n int) (int, error) {
+			if n%2 != 0 {
+				return 0, fmt.Errorf("%d is odd.", n)
+			}
+			return n / 2, nil
+		},
+		"apply":    func(f func(float64) float64, x float64) float64 { return f(x) },
+		"describe": func(v any) string { return fmt.Sprintf("%T", v) },
+		"noop":     func() {},
+		"explode":  func() { panic("boom") },
+		"first":    func(xs []float64) float64 { return xs[0] },
+		"fields":   func(s string) []string { return strings.Fields(s) },
+		"lookup":   func(m map[string]int, key string) int { return m[key] },
+		"counts": func(s string) map[string]int {
+			counts := map[string]int{}
+			for _, field := range strings.Fields(s) {
+				counts[field]++
+			}
+			return counts
+		},
+	}
+	for name, fn := range natives {
+		if err := r.RegisterNative(name, fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		source string
+		want   Value
+	}{
+		{"sum();", 0.0},
+		{"sum(1, 2, 3);", 6.0},
+		{`repeat("ab", 3);`, "ababab"},
+		{"half(10);", 5.0},
+		{"apply(fun (x) { return x * x; }, 4);", 16.0},
+		{"describe(nil);", "<nil>"},
+		{`describe("s");`, "string"},
+		{"noop();", nil},
+		{"sum;", "<native fn sum>"},
+		{"first([4, 5]);", 4.0},
+		{`fields("a b c");`, `["a", "b", "c"]`},
+		{`len(fields("a b c"));`, 3.0},
+		{`lookup({"a": 1, "b": 2}, "b");`, 2.0},
+		{`counts("b a b");`, `{"a": 1, "b": 2}`},
+	}
+	for _, tt := range tests {
+		value, err := r.Eval(ctx, tt.source)
+		if s, ok := tt.want.(string); ok && strings.IndexAny(s, "<[{") == 0 {
+			value = fmt.Sprint(value)
+		}
+		if err != nil || value != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.source, value, err, tt.want)
+		}
+	}
+
+	errorTests := []struct {
+		source  string
+		message string
+	}{
+		{"half(3);", "3 is odd."},
+		{"half(1.5);", "Argument 1 to 'half' must be an integer in range, not 1.5."},
+		{`repeat(1, 2);`, "Argument 1 to 'repeat' must be a string, not a number."},
+		{"repeat();", "Expected 2 arguments but got 0."},
+		{"sum(1, true);", "Argument 2 to 'sum' must be a number, not a boolean."},
+		{`apply(fun (x) { return "s"; }, 1);`, "Result of <fn anonymous> must be a number, not a string."},
+		{`apply(fun (x) { return -x + nil; }, 1);`, "Operands must be two numbers or two strings."},
+		{`lookup({"a": "1"}, "a");`, `Argument 1 to 'lookup' must be a map, but the value for "a" must be an integer, not a string.`},
+		{`first([1, "2"]);`, "Argument 1 to 'first' must be a list, but element 1 must be a number, not a string."},
+		{"explode();", "Native function 'explode' failed: boom."},
+		{"first([]);", "Native function 'first' failed: runtime error: index out of range [0] with length 0."},
+	}
+	for _, tt := range errorTests {
+		_, err := r.Eval(ctx, tt.source)
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Error() != tt.message {
+			t.Errorf("%s: got error %v, want %q", tt.source, err, tt.message)
+		}
+	}
+
+	if err := r.RegisterNative("bad", 42); err == nil {
+		t.Error("RegisterNative accepted a number")
+	}
+	if err := r.RegisterNative("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Error("RegisterNative accepted a function returning two values")
+	}
+}
+
+func TestStoredCallback(t *testing.T) {
+	r := NewRuntime()
+	var square func(float64) float64
+	var check func() error
+	r.RegisterNative("keep", func(f func(float64) float64, g func() error) float64 {
+		square, check = f, g
+		return f(3)
+	})
+
+	value, err := r.Eval(context.Background(), `keep(fun (x) { if (x > 3) return x + nil; return x * x; }, fun () { return nil; });`)
+	if err != nil || value != 9.0 {
+		t.Fatalf("keep(...) = %v, %v; want 9", value, err)
+	}
+
+	// After the native returns, the functions must neither run Lox code
+	// nor panic.
+	if got := square(4); got != 0 {
+		t.Errorf("stored callback returned %v, want 0", got)
+	}
+	if err := check(); !errors.Is(err, interpreter.ErrCallbackExpired) {
+		t.Errorf("stored callback returned %v, want ErrCallbackExpired", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	// repeated makes each call 100 times on a 1000 byte string s, which
+	// costs little unless the natives charge for what they build.
+	repeated := func(call string) string {
+		return fmt.Sprintf(`var s = "%s"; for (var k = 0; k < 100; k = k + 1) %s;`, strings.Repeat("ab", 500), call)
+	}
+	// doubled doubles s 20 times with step.
+	doubled := func(step string) string {
+		return fmt.Sprintf(`var s = "ab"; for (var k = 0; k < 20; k = k + 1) s = %s;`, step)
+	}
+	memory := interpreter.Limits{MaxMemory: 1 << 16}
+
+	tests := []struct {
+		name    string
+		limits  interpreter.Limits
+		source  string
+		message string
+		cause   error
+	}{
+		{"steps", interpreter.Limits{MaxSteps: 1000}, "while (true) {}", "Step limit exceeded.", interpreter.ErrStepLimit},
+		{"depth", interpreter.Limits{MaxCallDepth: 50}, "fun f(n) { return f(n + 1); } f(0);", "Stack overflow.", interpreter.ErrCallDepth},
+		{"default depth", interpreter.Limits{}, "fun f() { f(); } f();", "Stack overflow.", interpreter.ErrCallDepth},
+		{"memory", interpreter.Limits{MaxMemory: 1 << 16}, `var s = "ab"; while (true) s = s + s;`, "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		// The calls alone stay under the limit; what the natives add does not.
+		{"push", interpreter.Limits{MaxMemory: 20000}, `
+			var l = [];
+			var k = 0;
+			while (k < 100) {
+				push(l, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1);
+				k = k + 1;
+			}`, "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"sort", interpreter.Limits{MaxMemory: 20000}, `
+			var l = [1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1];
+			var k = 0;
+			while (k < 100) k = k + len(sort(l)) / 16;`, "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"keys", interpreter.Limits{MaxMemory: 20000}, `
+			var m = {1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9, 10: 10, 11: 11, 12: 12, 13: 13, 14: 14, 15: 15, 16: 16};
+			var k = 0;
+			while (k < 100) k = k + len(keys(m)) / 16;`, "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"values", interpreter.Limits{MaxMemory: 20000}, `
+			var m = {1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9, 10: 10, 11: 11, 12: 12, 13: 13, 14: 14, 15: 15, 16: 16};
+			var k = 0;
+			while (k < 100) k = k + len(values(m)) / 16;`, "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"map keys", interpreter.Limits{MaxMemory: 20000}, `
+			var m = {};
+			var k = 0;
+			while (k < 1000) m[k] = k = k + 1;`, "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"repeat", memory, `repeat("ab", 1000000);`, "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"format", memory, doubled(`format("%s%s", s, s)`), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"join", memory, doubled(`join([s, s], "")`), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"replace", memory, doubled(`replace(s, "a", "aa")`), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"split", memory, repeated(`split(s, "")`), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"upper", memory, repeated("upper(s)"), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"lower", memory, repeated("lower(s)"), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"substr", memory, repeated("substr(s, 1)"), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+		{"str", memory, repeated("str([s])"), "Memory limit exceeded.", interpreter.ErrMemoryLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRuntime()
+			r.SetLimits(tt.limits)
+
+			_, err := r.Eval(context.Background(), tt.source)
+			var runtimeErr *RuntimeError
+			if !errors.As(err, &runtimeErr) || runtimeErr.Error() != tt.message {
+				t.Fatalf("got %v, want runtime error %q", err, tt.message)
+			}
+			if !errors.Is(err, tt.cause) {
+				t.Errorf("error does not wrap %v", tt.cause)
+			}
+
+			// Each Eval gets a fresh budget.
+			if value, err := r.Eval(context.Background(), "1 + 1;"); err != nil || value != 2.0 {
+				t.Errorf("Eval after hitting the limit = %v, %v", value, err)
+			}
+		})
+	}
+}
+
+func TestEvalDeadline(t *testing.T) {
+	r := NewRuntime()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := r.Eval(ctx, "while (true) {}")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if err.Error() != "Execution timed out." {
+		t.Errorf("got message %q", err.Error())
+	}
+
+	r.Eval(context.Background(), "fun spin() { while (true) {} }")
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := r.CallContext(ctx, "spin"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LOX_TEST_VAR", "set")
+
+	denied := []struct {
+		source     string
+		capability interpreter.Capability
+	}{
+		{`readFile("in.txt");`, interpreter.CAP_FS_READ},
+		{`writeFile("out.txt", "x");`, interpreter.CAP_FS_WRITE},
+		{`getenv("LOX_TEST_VAR");`, interpreter.CAP_ENV},
+		{"clock();", interpreter.CAP_CLOCK},
+		{"random();", interpreter.CAP_RANDOM},
+		{"exit(1);", interpreter.CAP_EXIT},
+	}
+	for _, tt := range denied {
+		_, err := NewRuntime().Eval(ctx, tt.source)
+		var capabilityErr *interpreter.CapabilityError
+		if !errors.As(err, &capabilityErr) || capabilityErr.Capability != tt.capability {
+			t.Errorf("%s: got %v, want the %s capability denied", tt.source, err, tt.capability)
+		}
+		if !errors.Is(err, interpreter.ErrCapabilityDenied) {
+			t.Errorf("%s: error does not match ErrCapabilityDenied", tt.source)
+		}
+	}
+
+	// Two runtimes in one process with different permissions.
+	reader := NewRuntime()
+	reader.SetCapabilities(interpreter.Capabilities{FSRead: true, FSRoot: dir, Env: true, Exit: true})
+	sandboxed := NewRuntime()
+
+	if value, err := reader.Eval(ctx, `readFile("in.txt") + getenv("LOX_TEST_VAR");`); err != nil || value != "helloset" {
+		t.Errorf("readFile = %v, %v", value, err)
+	}
+	if _, err := sandboxed.Eval(ctx, `readFile("in.txt");`); !errors.Is(err, interpreter.ErrCapabilityDenied) {
+		t.Errorf("sandboxed readFile: got %v", err)
+	}
+	if _, err := reader.Eval(ctx, `readFile("../in.txt");`); err == nil {
+		t.Error("read outside FSRoot succeeded")
+	}
+	if _, err := reader.Eval(ctx, `writeFile("out.txt", "x");`); !errors.Is(err, interpreter.ErrCapabilityDenied) {
+		t.Errorf("writeFile without fs.write: got %v", err)
+	}
+
+	var exit *interpreter.ExitError
+	if _, err := reader.Eval(ctx, "exit(4); 1;"); !errors.As(err, &exit) || exit.Code != 4 {
+		t.Errorf("exit: got %v", err)
+	}
+
+	timer := NewRuntime()
+	timer.SetCapabilities(interpreter.Capabilities{Clock: true})
+	before, err := timer.Eval(ctx, "clock();")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	after, _ := timer.Eval(ctx, "clock();")
+	if elapsed := after.(float64) - before.(float64); elapsed < 0.01 || elapsed > 1 {
+		t.Errorf("clock advanced %v seconds over 20ms", elapsed)
+	}
+	if value, _ := timer.Eval(ctx, "clock;"); fmt.Sprint(value) != "<native fn clock>" {
+		t.Errorf("clock prints as %v", value)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var stdout bytes.Buffer
+	r := NewRuntime()
+	r.SetOutput(&stdout)
+
+	if _, err := r.Eval(context.Background(), `print "hello"; print 1 + 2;`); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "hello\n3\n" {
+		t.Errorf("output = %q, want %q", got, "hello\n3\n")
+	}
+
+	var out, stderr bytes.Buffer
+	l := New()
+	l.SetOutput(io.Discard, &stderr)
+	l.RunPrompt(strings.NewReader("print 1;\n2 + 3;\nprint nil + 1;\n"), &out)
+	if got, want := out.String(), PROMPT+"1\n"+PROMPT+"5\n"+PROMPT+PROMPT; got != want {
+		t.Errorf("prompt output = %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(stderr.String(), "Operands must be two numbers or two strings.\n") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	r := NewRuntime()
+	if err := r.RegisterNative("apply", func(f func(float64) float64, x float64) float64 { return f(x) }); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := r.Eval(context.Background(), `fun fail(n) {
+  return n + nil;
+}
+fun outer() {
+  return apply(fail, 1);
+}
+outer();`)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("got %v, want a RuntimeError", err)
+	}
+	want := []loxerrors.Frame{{Function: "fail", Line: 2}, {Function: "outer", Line: 5}, {Line: 7}}
+	if !reflect.DeepEqual(runtimeErr.Frames, want) {
+		t.Errorf("Frames = %v, want %v", runtimeErr.Frames, want)
+	}
+	notes := runtimeErr.Diagnostic.Notes
+	if len(notes) != 3 || notes[0] != "[line 2] in fail()" || notes[2] != "[line 7] in script" {
+		t.Errorf("Notes = %q", notes)
+	}
+
+	// Calls from Go have no script frame.
+	_, err = r.Call("fail", 1)
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("got %v, want a RuntimeError", err)
+	}
+	want = []loxerrors.Frame{{Function: "fail", Line: 2}}
+	if !reflect.DeepEqual(runtimeErr.Frames, want) {
+		t.Errorf("Frames = %v, want %v", runtimeErr.Frames, want)
+	}
+}
+
+func TestThrow(t *testing.T) {
+	ctx := context.Background()
+	r := NewRuntime()
+
+	_, err := r.Eval(ctx, `throw "bad";`)
+	var thrown *interpreter.ThrowError
+	if !errors.As(err, &thrown) || thrown.Value != "bad" {
+		t.Errorf("got %v, want a ThrowError for \"bad\"", err)
+	}
+
+	// Hitting a limit stops the program even inside try.
+	r.SetLimits(interpreter.Limits{MaxSteps: 1000})
+	_, err = r.Eval(ctx, `try { while (true) {} } catch (e) { print "caught"; }`)
+	if !errors.Is(err, interpreter.ErrStepLimit) {
+		t.Errorf("got %v, want ErrStepLimit", err)
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	r := NewRuntime()
+	ctx := context.Background()
+	if _, err := r.Eval(ctx, "fun fib(n) { if (n <= 1) return n; return fib(n - 2) + fib(n - 1); }"); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := r.Eval(ctx, "fib(20);"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
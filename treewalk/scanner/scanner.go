@@ -1,9 +1,13 @@
 package scanner
 
 import (
+	"fmt"
 	"lox/treewalk/loxerrors"
 	"lox/treewalk/token"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Scanner struct {
@@ -13,10 +17,18 @@ type Scanner struct {
 	current  int
 	line     int
 	loxerror *loxerrors.LoxErrors
+	// interpolations holds, for each "${" still open, how many '{' are open
+	// inside it, so the scanner knows which '}' goes back into the string.
+	interpolations []int
+	// columnOffset and columnNumber remember the last column worked out,
+	// so that columns are counted once for the whole source rather than
+	// from the start of the line for every token.
+	columnOffset int
+	columnNumber int
 }
 
 func New(source string, loxerror *loxerrors.LoxErrors) *Scanner {
-	return &Scanner{source: source, line: 1, loxerror: loxerror}
+	return &Scanner{source: source, line: 1, loxerror: loxerror, columnNumber: 1}
 }
 
 func (s *Scanner) ScanTokens() []token.Token {
@@ -25,7 +37,8 @@ func (s *Scanner) ScanTokens() []token.Token {
 		s.scanToken()
 	}
 
-	s.tokens = append(s.tokens, token.New(token.EOF, "", "null", s.line))
+	s.start = s.current
+	s.tokens = append(s.tokens, token.NewAt(token.EOF, "", "null", s.line, s.current, s.column()))
 	return s.tokens
 }
 
@@ -37,11 +50,28 @@ func (s *Scanner) scanToken() {
 	case ')':
 		s.addToken(token.RIGHT_PAREN)
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1]++
+		}
 		s.addToken(token.LEFT_BRACE)
 	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				s.interpolations = s.interpolations[:n-1]
+				s.str()
+				return
+			}
+			s.interpolations[n-1]--
+		}
 		s.addToken(token.RIGHT_BRACE)
+	case '[':
+		s.addToken(token.LEFT_BRACKET)
+	case ']':
+		s.addToken(token.RIGHT_BRACKET)
 	case ',':
 		s.addToken(token.COMMA)
+	case ':':
+		s.addToken(token.COLON)
 	case '.':
 		s.addToken(token.DOT)
 	case '-':
@@ -94,8 +124,10 @@ func (s *Scanner) scanToken() {
 			s.number()
 		} else if isAlpha(c) {
 			s.identifier()
+		} else if c == utf8.RuneError && s.current-s.start == 1 {
+			s.loxerror.ErrorAt(s.line, s.span(), "Invalid UTF-8 encoding.")
 		} else {
-			s.loxerror.Error(s.line, "Unexpected character: "+string(c))
+			s.loxerror.ErrorAt(s.line, s.span(), "Unexpected character: "+string(c))
 		}
 	}
 }
@@ -104,9 +136,11 @@ func (s Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
-func (s *Scanner) advance() byte {
-	s.current++
-	return s.source[s.current-1]
+// advance consumes the next character, which may take several bytes.
+func (s *Scanner) advance() rune {
+	c, size := utf8.DecodeRuneInString(s.source[s.current:])
+	s.current += size
+	return c
 }
 
 func (s *Scanner) addToken(typ token.TokenType) {
@@ -115,56 +149,205 @@ func (s *Scanner) addToken(typ token.TokenType) {
 
 func (s *Scanner) addTokenWithLiteral(typ token.TokenType, literal any) {
 	text := s.source[s.start:s.current]
-	tok := token.New(typ, text, literal, s.line)
+	tok := token.NewAt(typ, text, literal, s.line, s.start, s.column())
 	s.tokens = append(s.tokens, tok)
 }
 
-func (s *Scanner) match(expected byte) bool {
-	if s.isAtEnd() {
-		return false
+// column is the rune column of the current lexeme's first character.
+func (s *Scanner) column() int {
+	return s.columnAt(s.start)
+}
+
+// columnAt is the rune column of the character at byte offset.
+func (s *Scanner) columnAt(offset int) int {
+	if offset < s.columnOffset {
+		lineStart := strings.LastIndexByte(s.source[:offset], '\n') + 1
+		s.columnOffset, s.columnNumber = lineStart, 1
 	}
-	if s.source[s.current] != expected {
-		return false
+	for s.columnOffset < offset {
+		c, size := utf8.DecodeRuneInString(s.source[s.columnOffset:])
+		s.columnOffset += size
+		s.columnNumber++
+		if c == '\n' {
+			s.columnNumber = 1
+		}
 	}
+	return s.columnNumber
+}
+
+func (s *Scanner) span() token.Span {
+	return token.Span{Start: s.start, End: s.current, Line: s.line, Column: s.column()}
+}
 
-	s.current++
+// errorFrom reports a syntax error covering the source from byte offset
+// start to the current character.
+func (s *Scanner) errorFrom(start int, message string) {
+	s.loxerror.ErrorAt(s.line, token.Span{Start: start, End: s.current, Line: s.line, Column: s.columnAt(start)}, message)
+}
+
+func (s *Scanner) match(expected rune) bool {
+	if s.peek() != expected || s.isAtEnd() {
+		return false
+	}
+	s.advance()
 	return true
 }
 
-func (s *Scanner) peek() byte {
+func (s *Scanner) peek() rune {
 	if s.isAtEnd() {
-		return byte(0)
+		return 0
 	}
-	return s.source[s.current]
+	c, _ := utf8.DecodeRuneInString(s.source[s.current:])
+	return c
 }
 
-func (s *Scanner) peekNext() byte {
-	if s.current+1 >= len(s.source) {
-		return byte(0)
+func (s *Scanner) peekNext() rune {
+	if s.isAtEnd() {
+		return 0
 	}
-	return s.source[s.current+1]
+	_, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if s.current+size >= len(s.source) {
+		return 0
+	}
+	c, _ := utf8.DecodeRuneInString(s.source[s.current+size:])
+	return c
 }
 
+// str scans a string literal after its opening quote, or the rest of one
+// after the '}' that closes an interpolated expression. Escape sequences are
+// replaced by the characters they stand for. Text that ends at a "${" becomes
+// an INTERPOLATION token, and the expression after it is scanned as usual.
+// Three quotes start a raw string instead.
 func (s *Scanner) str() {
+	line := s.line
+	if s.source[s.start] == '"' && s.peek() == '"' && s.peekNext() == '"' {
+		s.advance()
+		s.advance()
+		s.rawString()
+		return
+	}
+
+	var value strings.Builder
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
+		c := s.advance()
+		switch c {
+		case '\\':
+			s.escape(&value)
+			continue
+		case '$':
+			if s.match('{') {
+				s.interpolations = append(s.interpolations, 0)
+				s.addTokenWithLiteral(token.INTERPOLATION, value.String())
+				return
+			}
+		case '\n':
 			s.line++
 		}
-		s.advance()
+		value.WriteRune(c)
 	}
 
 	if s.isAtEnd() {
 		// fmt.Fprintf(os.Stderr, "%d: Unterminated string", s.line)
-		s.loxerror.Error(s.line, "Unterminated string.")
+		s.unterminated(line)
 		return
 	}
 
 	s.advance()
+	s.addTokenWithLiteral(token.STRING, value.String())
+}
+
+// escape decodes the escape sequence after a backslash into value.
+func (s *Scanner) escape(value *strings.Builder) {
+	start := s.current - 1
+	if s.isAtEnd() {
+		return
+	}
+
+	switch c := s.advance(); c {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '0':
+		value.WriteByte(0)
+	case '"', '\\', '$':
+		value.WriteRune(c)
+	case 'u':
+		s.unicodeEscape(start, value)
+	default:
+		if c == '\n' {
+			s.line++
+		}
+		s.errorFrom(start, fmt.Sprintf("Invalid escape sequence '\\%c'.", c))
+	}
+}
+
+// unicodeEscape decodes the code point of a \uXXXX or \u{X...} escape
+// that began at byte offset start.
+func (s *Scanner) unicodeEscape(start int, value *strings.Builder) {
+	braced := s.match('{')
+	digitsStart := s.current
+	for isHexDigit(s.peek()) && (braced || s.current-digitsStart < 4) {
+		s.advance()
+	}
+	digits := s.source[digitsStart:s.current]
+
+	valid := len(digits) == 4
+	if braced {
+		valid = len(digits) >= 1 && len(digits) <= 6 && s.match('}')
+	}
+	if !valid {
+		s.errorFrom(start, "Invalid Unicode escape sequence.")
+		return
+	}
+
+	code, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(code)) {
+		s.errorFrom(start, "Invalid Unicode code point.")
+		return
+	}
+	value.WriteRune(rune(code))
+}
 
-	value := s.source[s.start+1 : s.current-1]
+// rawString scans the rest of a string that began with three quotes. It
+// ends at the next three quotes, may span lines and has no escapes. A line
+// break right after the opening quotes is not part of the string.
+func (s *Scanner) rawString() {
+	line := s.line
+	if strings.HasPrefix(s.source[s.current:], "\r\n") {
+		s.current++
+	}
+	if s.match('\n') {
+		s.line++
+	}
+	valueStart := s.current
+
+	for !strings.HasPrefix(s.source[s.current:], `"""`) && !s.isAtEnd() {
+		if s.advance() == '\n' {
+			s.line++
+		}
+	}
+
+	if s.isAtEnd() {
+		s.unterminated(line)
+		return
+	}
+
+	value := s.source[valueStart:s.current]
+	s.current += len(`"""`)
 	s.addTokenWithLiteral(token.STRING, value)
 }
 
+// unterminated reports a string that runs to the end of the source at
+// line, where it opened, rather than at the last line.
+func (s *Scanner) unterminated(line int) {
+	span := s.span()
+	span.Line = line
+	s.loxerror.ErrorAt(line, span, "Unterminated string.")
+}
+
 func (s *Scanner) number() {
 	for isDigit(s.peek()) {
 		s.advance()
@@ -192,16 +375,20 @@ func (s *Scanner) identifier() {
 	s.addToken(typ)
 }
 
-func isDigit(c byte) bool {
+func isDigit(c rune) bool {
 	return c >= '0' && c <= '9'
 }
 
-func isAlpha(c byte) bool {
-	return (c >= 'a' && c <= 'z') ||
-		(c >= 'A' && c <= 'Z') ||
-		c == '_'
+func isHexDigit(c rune) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isAlpha reports whether c can start an identifier: a letter in any script
+// or an underscore.
+func isAlpha(c rune) bool {
+	return unicode.IsLetter(c) || c == '_'
 }
 
-func isAlphaNumeric(c byte) bool {
-	return isAlpha(c) || isDigit(c)
+func isAlphaNumeric(c rune) bool {
+	return isAlpha(c) || unicode.IsDigit(c)
 }
@@ -10,7 +10,10 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
+	COLON
 	DOT
 	MINUS
 	PLUS
@@ -31,6 +34,8 @@ const (
 	// literals
 	IDENTIFIER
 	STRING
+	// INTERPOLATION is the text of a string literal up to a "${"
+	INTERPOLATION
 	NUMBER
 
 	// keywords
@@ -52,6 +57,13 @@ const (
 	VAR
 	WHILE
 
+	TRY
+	CATCH
+	FINALLY
+	THROW
+	BREAK
+	CONTINUE
+
 	EOF
 )
 
@@ -60,7 +72,10 @@ var tokenTypes = [...]string{
 	"RIGHT_PAREN",
 	"LEFT_BRACE",
 	"RIGHT_BRACE",
+	"LEFT_BRACKET",
+	"RIGHT_BRACKET",
 	"COMMA",
+	"COLON",
 	"DOT",
 	"MINUS",
 	"PLUS",
@@ -77,6 +92,7 @@ var tokenTypes = [...]string{
 	"LESS_EQUAL",
 	"IDENTIFIER",
 	"STRING",
+	"INTERPOLATION",
 	"NUMBER",
 	"AND",
 	"CLASS",
@@ -94,33 +110,64 @@ var tokenTypes = [...]string{
 	"TRUE",
 	"VAR",
 	"WHILE",
+	"TRY",
+	"CATCH",
+	"FINALLY",
+	"THROW",
+	"BREAK",
+	"CONTINUE",
 	"EOF",
 }
 
 var keywords = map[string]TokenType{
-	"and":    AND,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":      AND,
+	"break":    BREAK,
+	"catch":    CATCH,
+	"class":    CLASS,
+	"continue": CONTINUE,
+	"else":     ELSE,
+	"false":    FALSE,
+	"finally":  FINALLY,
+	"for":      FOR,
+	"fun":      FUN,
+	"if":       IF,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"super":    SUPER,
+	"this":     THIS,
+	"throw":    THROW,
+	"true":     TRUE,
+	"try":      TRY,
+	"var":      VAR,
+	"while":    WHILE,
 }
 
+// Token is a lexeme together with where it was found. Offset is a byte
+// offset into the source and Column counts runes from 1.
 type Token struct {
 	Typ     TokenType
 	Lexeme  string
 	Literal any
 	Line    int
+	Offset  int
+	Column  int
+	Length  int
+}
+
+// Span is a range of source text. Start and End are byte offsets, End
+// exclusive. Line and Column locate Start.
+type Span struct {
+	Start  int
+	End    int
+	Line   int
+	Column int
+}
+
+// To returns the span running from the start of s to the end of end.
+func (s Span) To(end Span) Span {
+	return Span{Start: s.Start, End: end.End, Line: s.Line, Column: s.Column}
 }
 
 func LookupIdent(ident string) TokenType {
@@ -131,7 +178,16 @@ func LookupIdent(ident string) TokenType {
 }
 
 func New(typ TokenType, lexme string, literal any, line int) Token {
-	return Token{typ, lexme, literal, line}
+	return Token{Typ: typ, Lexeme: lexme, Literal: literal, Line: line, Length: len(lexme)}
+}
+
+// NewAt is New for a token found at offset and column in the source.
+func NewAt(typ TokenType, lexme string, literal any, line, offset, column int) Token {
+	return Token{typ, lexme, literal, line, offset, column, len(lexme)}
+}
+
+func (t Token) Span() Span {
+	return Span{Start: t.Offset, End: t.Offset + t.Length, Line: t.Line, Column: t.Column}
 }
 
 func (t Token) String() string {